@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/JojiiOfficial/gaw"
+	"github.com/RemoteBuild/Remotebuild/storage"
+)
+
+// Apply pending migrations
+func migrateDB() {
+	applied, err := storage.Migrate(db)
+	if LogError(err) {
+		os.Exit(1)
+	}
+
+	if applied == 0 {
+		fmt.Println("Database is up to date")
+		return
+	}
+
+	fmt.Printf("Applied %d migrations\n", applied)
+}
+
+// Print the schema version and all migrations
+func dbStatus() {
+	version, err := storage.SchemaVersion(db)
+	if LogError(err) {
+		os.Exit(1)
+	}
+
+	status, err := storage.GetMigrationStatus(db)
+	if LogError(err) {
+		os.Exit(1)
+	}
+
+	fmt.Printf("Schema version: %d (expected: %d)\n", version, storage.LatestVersion())
+	if version > storage.LatestVersion() {
+		fmt.Println("The database was migrated by a newer version")
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Version\tDescription\tApplied")
+	for _, migration := range status {
+		applied := "pending"
+		if migration.Applied {
+			applied = migration.AppliedAt.Format(time.Stamp)
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\n", migration.Version, migration.Description, applied)
+	}
+	w.Flush()
+}
+
+// Revert the latest n migrations
+func rollbackDB(n int, yes bool) {
+	plan, err := storage.RollbackPlan(db, n)
+	if err == nil && len(plan) == 0 {
+		fmt.Println("Nothing to roll back")
+		return
+	}
+	if LogError(err) {
+		os.Exit(1)
+	}
+
+	fmt.Println("The following migrations will be reverted:")
+	for _, migration := range plan {
+		fmt.Printf("  %d: %s\n", migration.Version, migration.Description)
+	}
+
+	if !yes {
+		if y, _ := gaw.ConfirmInput("Do you really want to roll back? Reverted changes may delete data [y/n]> ", bufio.NewReader(os.Stdin)); !y {
+			return
+		}
+	}
+
+	reverted, err := storage.Rollback(db, n)
+	if reverted > 0 {
+		fmt.Printf("Reverted %d migrations\n", reverted)
+	}
+	if LogError(err) {
+		os.Exit(1)
+	}
+}
+
+// Export the database into a portable archive
+func exportDB(file string, sessions, secrets bool) {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if LogError(err) {
+		os.Exit(1)
+	}
+
+	archive, err := storage.Export(db, f, storage.ExportOptions{
+		Sessions:         sessions,
+		Secrets:          secrets,
+		LocalStoragePath: config.Server.LocalStoragePath,
+	})
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+
+	if LogError(err) {
+		os.Remove(file)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Exported %d users, %d jobs, %d queued jobs, %d schedules and %d artifacts\n",
+		len(archive.Users), len(archive.Jobs), len(archive.Queue), len(archive.Schedules), len(archive.Artifacts))
+	fmt.Println("Artifacts aren't part of the archive. Copy the local storage path separately")
+	fmt.Println("The archive contains password hashes. Keep it private")
+	if secrets {
+		fmt.Println("Secret args of unfinished jobs and schedules are included in plain text")
+	}
+}
+
+// Import an archive into an empty database
+func importDB(file string) {
+	f, err := os.Open(file)
+	if LogError(err) {
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	archive, err := storage.Import(db, f)
+	if LogError(err) {
+		os.Exit(1)
+	}
+
+	fmt.Printf("Imported %d users, %d jobs, %d queued jobs and %d schedules\n",
+		len(archive.Users), len(archive.Jobs), len(archive.Queue), len(archive.Schedules))
+
+	if ids := archive.JobsWithoutSecrets(); len(ids) > 0 {
+		fmt.Printf("%d queued jobs lost their secret args and might fail: %v\n", len(ids), ids)
+	}
+
+	if ids := archive.SchedulesWithoutSecrets(); len(ids) > 0 {
+		fmt.Printf("%d schedules lost their secret args and won't create jobs until they are recreated: %v\n", len(ids), ids)
+	}
+
+	if missing := archive.MissingArtifacts(config.Server.LocalStoragePath); len(missing) > 0 {
+		fmt.Printf("%d artifacts are missing in %s:\n", len(missing), config.Server.LocalStoragePath)
+		for _, artifact := range missing {
+			fmt.Println(" ", artifact.Name)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/JojiiOfficial/gaw"
+	"github.com/RemoteBuild/Remotebuild/models"
+	"github.com/RemoteBuild/Remotebuild/services"
+)
+
+// List the queued jobs or the latest jobs if all is set
+func listJobs(all bool, limit int) {
+	var jobs []models.Job
+
+	positions := make(map[uint]uint)
+	if all {
+		err := db.Model(&models.Job{}).
+			Preload("BuildJob").
+			Preload("UploadJob").
+			Order("id DESC").
+			Limit(limit).
+			Find(&jobs).Error
+		if LogError(err) {
+			os.Exit(1)
+		}
+	} else {
+		items, err := services.GetQueueItems(db)
+		if LogError(err) {
+			os.Exit(1)
+		}
+
+		for _, item := range items {
+			jobs = append(jobs, *item.Job)
+			positions[item.JobID] = item.Position
+		}
+	}
+
+	names, err := models.GetUsernames(db, jobOwners(jobs))
+	if LogError(err) {
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPos\tState\tInfo\tOwner\tCreated")
+	for i := range jobs {
+		if jobs[i].BuildJob == nil || jobs[i].UploadJob == nil {
+			continue
+		}
+
+		pos := "-"
+		if p, ok := positions[jobs[i].ID]; ok {
+			pos = fmt.Sprint(p)
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", jobs[i].ID, pos, models.StateName(jobs[i].GetState()),
+			jobs[i].Info, names[jobs[i].UserID], jobs[i].CreatedAt.Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+}
+
+// Return the IDs of the owners of the jobs
+func jobOwners(jobs []models.Job) []uint {
+	ids := make([]uint, 0, len(jobs))
+	for i := range jobs {
+		ids = append(ids, jobs[i].UserID)
+	}
+
+	return ids
+}
+
+// Show details of a job
+func showJob(jobID uint) {
+	job := loadJob(jobID)
+	info := job.ToJobInfo()
+
+	names, err := models.GetUsernames(db, []uint{job.UserID})
+	if LogError(err) {
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%d\n", info.ID)
+	fmt.Fprintf(w, "Info:\t%s\n", info.Info)
+	fmt.Fprintf(w, "State:\t%s\n", info.StateName)
+	fmt.Fprintf(w, "Owner:\t%s\n", names[job.UserID])
+	fmt.Fprintf(w, "Build type:\t%s\n", info.BuildType.String())
+	fmt.Fprintf(w, "Upload type:\t%s\n", info.UploadType.String())
+	fmt.Fprintf(w, "Created:\t%s\n", job.CreatedAt.Format(time.Stamp))
+	fmt.Fprintf(w, "Duration:\t%s\n", info.Duration)
+	fmt.Fprintf(w, "Attempts:\t%d\n", info.Attempts)
+	if info.BatchID > 0 {
+		fmt.Fprintf(w, "Batch:\t%d\n", info.BatchID)
+	}
+	if len(job.Result) > 0 {
+		fmt.Fprintf(w, "Result:\t%s\n", job.Result)
+	}
+	w.Flush()
+
+	if info.Timeline != nil && len(info.Timeline.Entries) > 0 {
+		fmt.Println("\nTimeline:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, entry := range info.Timeline.Entries {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", entry.Start.Format(time.Stamp), entry.Stage, entry.Duration.Round(time.Second), entry.Reason)
+		}
+		w.Flush()
+	}
+
+	if len(info.FailedAttempts) > 0 {
+		fmt.Println("\nFailed attempts:")
+		for _, attempt := range info.FailedAttempts {
+			fmt.Printf("  #%d %s (%s): %s\n", attempt.Attempt, attempt.Time.Format(time.Stamp), attempt.Phase, attempt.Error)
+		}
+	}
+}
+
+// Cancel a queued or running job
+func cancelJob(jobID uint, fail bool) {
+	reason := "Cancelled via CLI"
+	if fail {
+		reason = "Failed via CLI"
+	}
+
+	err := services.AbortJob(db, jobID, fail, reason)
+	switch err {
+	case nil:
+	case services.ErrJobNotFound, services.ErrJobFinished:
+		fmt.Println(err)
+		os.Exit(1)
+	default:
+		LogError(err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Cancelled job", jobID)
+}
+
+// Remove all jobs from the queue
+func purgeQueue(yes bool) {
+	if !yes {
+		if y, _ := gaw.ConfirmInput("Do you really want to remove all jobs from the queue? [y/n]> ", bufio.NewReader(os.Stdin)); !y {
+			return
+		}
+	}
+
+	purged, err := services.PurgeQueue(db, "Purged via CLI")
+	if LogError(err) {
+		os.Exit(1)
+	}
+
+	fmt.Printf("Removed %d jobs from the queue\n", purged)
+}
+
+// Print the logs of a job. Prefers the log archive
+// over the latest logs stored in the db
+func printJobLogs(jobID uint) {
+	job := loadJob(jobID)
+
+	if path := config.GetLogArchivePath(job.ID); len(path) > 0 && gaw.FileExists(path) {
+		var offset int64
+		for {
+			page, err := models.ReadLogArchive(path, offset, 0, false)
+			if LogError(err) {
+				os.Exit(1)
+			}
+
+			os.Stdout.Write(page.Content)
+			if page.EOF || page.Next == offset {
+				return
+			}
+			offset = page.Next
+		}
+	}
+
+	if len(job.LastLogs) == 0 {
+		fmt.Println("No logs found")
+		return
+	}
+
+	fmt.Println(job.LastLogs)
+}
+
+// Load a job or exit if it doesn't exist
+func loadJob(jobID uint) *models.Job {
+	job, err := models.LoadJob(db, jobID)
+	if LogError(err) {
+		os.Exit(1)
+	}
+
+	if job == nil || job.BuildJob == nil || job.UploadJob == nil {
+		fmt.Println("Job not found")
+		os.Exit(1)
+	}
+
+	return job
+}
@@ -22,6 +22,7 @@ var (
 	jobService       *services.JobService       // Handle Jobs
 	cleanupService   *services.CleanupService   // Cleanup db stuff
 	containerService *services.ContainerService // Managing containers
+	schedulerService *services.SchedulerService // Run scheduled jobs
 )
 
 func startAPI() {
@@ -36,9 +37,13 @@ func startAPI() {
 	})
 	jobService.Start()
 
+	// Create and start the scheduler
+	schedulerService = services.NewSchedulerService(config, db, jobService.Queue)
+	schedulerService.Start()
+
 	// Create and start required services
 	apiService = services.NewAPIService(config, func() *mux.Router {
-		return handlers.NewRouter(config, db, jobService)
+		return handlers.NewRouter(config, db, jobService, schedulerService)
 	})
 	apiService.Start()
 
@@ -57,11 +62,21 @@ func awaitExit(httpServer *services.APIService, db *gorm.DB) {
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGINT, os.Interrupt, syscall.SIGKILL, syscall.SIGTERM)
 
-	// await os signal
-	<-signalChan
-
-	// Stop all jobs
-	jobService.Stop()
+	// SIGUSR1 starts draining
+	drainChan := make(chan os.Signal, 1)
+	signal.Notify(drainChan, syscall.SIGUSR1)
+
+	// await os signal or drain
+	select {
+	case <-signalChan:
+		// Stop all jobs
+		jobService.Stop()
+	case <-drainChan:
+		jobService.Drain()
+		awaitDrained(signalChan)
+	case <-jobService.Draining():
+		awaitDrained(signalChan)
+	}
 
 	// Create a deadline for the await
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
@@ -90,3 +105,19 @@ func awaitExit(httpServer *services.APIService, db *gorm.DB) {
 	log.Info("Shutting down complete")
 	os.Exit(0)
 }
+
+// Wait for running jobs to finish. A
+// signal on signalChan stops them instantly
+func awaitDrained(signalChan chan os.Signal) {
+	done := make(chan struct{})
+	go func() {
+		jobService.AwaitDrained()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-signalChan:
+		jobService.Stop()
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/JojiiOfficial/gaw"
+	"github.com/RemoteBuild/Remotebuild/models"
+	"golang.org/x/crypto/ssh/terminal"
+	"gorm.io/gorm"
+)
+
+// Create a new user
+func createUser(username, password string, passwordStdin bool, roleName string) {
+	role, ok := models.ParseRole(roleName)
+	if !ok {
+		fmt.Println("Invalid role:", roleName)
+		os.Exit(1)
+	}
+
+	password, err := readPassword(password, passwordStdin)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	user := models.User{
+		Username: username,
+		Password: password,
+	}
+
+	// Don't keep the user if setting the role fails
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := user.Register(tx, config); err != nil {
+			return err
+		}
+
+		return models.SetRole(tx, username, role)
+	})
+	if err == models.ErrorUserAlreadyExists {
+		fmt.Println("User already exists")
+		os.Exit(1)
+	}
+	if LogError(err) {
+		os.Exit(1)
+	}
+
+	fmt.Printf("Created %s user %s\n", role.Name, user.GetUsername())
+}
+
+// Delete a user and kill all of its sessions
+func deleteUser(username string, yes bool) {
+	if !yes {
+		if y, _ := gaw.ConfirmInput(fmt.Sprintf("Do you really want to delete %s? [y/n]> ", username), bufio.NewReader(os.Stdin)); !y {
+			return
+		}
+	}
+
+	err := models.DeleteUser(db, username)
+	if err == models.ErrUserNotFound {
+		fmt.Println("User not found")
+		os.Exit(1)
+	}
+	if LogError(err) {
+		os.Exit(1)
+	}
+
+	fmt.Println("Deleted user", username)
+}
+
+// List all users
+func listUsers() {
+	users, err := models.GetUsers(db)
+	if LogError(err) {
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUsername\tRole\tCreated")
+	for _, user := range users {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", user.ID, user.Username, user.GetRoleName(), user.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+}
+
+// Change the password of a user
+func changePassword(username, password string, passwordStdin bool) {
+	var user models.User
+	if err := db.Where("username = ?", strings.ToLower(username)).First(&user).Error; err != nil {
+		fmt.Println("User not found")
+		os.Exit(1)
+	}
+
+	password, err := readPassword(password, passwordStdin)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if LogError(user.SetPassword(db, password, config.Server.PasswordCost)) {
+		os.Exit(1)
+	}
+
+	fmt.Println("Password changed")
+}
+
+// Set the role of a user
+func setUserRole(username, roleName string) {
+	role, ok := models.ParseRole(roleName)
+	if !ok {
+		fmt.Println("Invalid role:", roleName)
+		os.Exit(1)
+	}
+
+	err := models.SetRole(db, username, role)
+	if err == models.ErrUserNotFound {
+		fmt.Println("User not found")
+		os.Exit(1)
+	}
+	if LogError(err) {
+		os.Exit(1)
+	}
+
+	fmt.Printf("Role of %s set to %s\n", username, role.Name)
+}
+
+// Return the password passed as flag, read it from
+// stdin or prompt for it if a terminal is attached
+func readPassword(password string, fromStdin bool) (string, error) {
+	if len(password) > 0 {
+		return password, nil
+	}
+
+	if fromStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && len(line) == 0 {
+			return "", err
+		}
+
+		password = strings.TrimRight(line, "\r\n")
+		if len(password) == 0 {
+			return "", errors.New("Empty password")
+		}
+
+		return password, nil
+	}
+
+	fd := int(syscall.Stdin)
+	if !terminal.IsTerminal(fd) {
+		return "", errors.New("No password given. Use --password or --password-stdin")
+	}
+
+	fmt.Print("Password: ")
+	pass, err := terminal.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Print("Repeat password: ")
+	repeat, err := terminal.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+
+	if string(pass) != string(repeat) {
+		return "", errors.New("Passwords don't match")
+	}
+	if len(pass) == 0 {
+		return "", errors.New("Empty password")
+	}
+
+	return string(pass), nil
+}
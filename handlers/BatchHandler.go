@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
+	"github.com/RemoteBuild/Remotebuild/models"
+	"github.com/RemoteBuild/Remotebuild/services"
+)
+
+// addBatch add multiple jobs in one transaction
+func addBatch(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.AddBatchRequest
+
+	// Read request
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
+		return
+	}
+
+	if len(request.Jobs) == 0 {
+		sendResponse(w, models.ResponseError, "no jobs given", nil, http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Validate all jobs before creating any
+	options := make([]services.JobOptions, len(request.Jobs))
+	for i := range request.Jobs {
+		var ok bool
+		if options[i], ok = parseJobOptions(handlerData, w, request.Jobs[i]); !ok {
+			return
+		}
+		options[i].UserID = handlerData.User.ID
+	}
+
+	items, err := handlerData.JobService.Queue.AddBatch(handlerData.Db, options)
+	if err == models.ErrDependencyNotFound {
+		sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusUnprocessableEntity)
+		return
+	}
+	if err == services.ErrQueueDraining {
+		sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusServiceUnavailable)
+		return
+	}
+	if LogError(err) {
+		sendServerError(w)
+		return
+	}
+
+	response := models.AddBatchResponse{
+		BatchID: items[0].Job.BatchID,
+		Jobs:    make([]libremotebuild.AddJobResponse, len(items)),
+	}
+
+	for i, item := range items {
+		response.Jobs[i] = libremotebuild.AddJobResponse{
+			ID:       item.JobID,
+			Position: handlerData.JobService.Queue.GetJobQueuePos(item),
+		}
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", response)
+}
+
+// batchInfo returns the status of a batch and its jobs
+func batchInfo(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.BatchRequest
+
+	// Read request
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
+		return
+	}
+
+	batch, jobs, err := handlerData.JobService.GetBatch(request.BatchID)
+	if LogError(err) {
+		sendServerError(w)
+		return
+	}
+
+	if !canAccessBatch(handlerData, batch) {
+		sendResponse(w, models.ResponseError, "no such batch found", nil, http.StatusNotFound)
+		return
+	}
+
+	jobInfos := make([]models.JobInfo, len(jobs))
+	for i := range jobs {
+		jobInfos[i] = jobs[i].ToJobInfo()
+
+		// Add the position of queued jobs
+		if item := handlerData.JobService.Queue.FindJob(jobs[i].ID); item != nil {
+			jobInfos[i].Position = uint(handlerData.JobService.Queue.GetJobQueuePos(item))
+		}
+	}
+	setJobOwners(handlerData, jobInfos)
+
+	sendResponse(w, models.ResponseSuccess, "", models.NewBatchInfo(*batch, jobInfos))
+}
+
+// listBatches lists the latest batches
+func listBatches(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.ListBatchesRequest
+
+	// Read request
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
+		return
+	}
+
+	limit := 10
+	if request.Limit > 0 {
+		limit = request.Limit
+	}
+
+	// Users only see their own batches
+	var userID uint
+	if !handlerData.User.IsAdmin() {
+		userID = handlerData.User.ID
+	}
+
+	batches, err := handlerData.JobService.GetBatches(limit, userID)
+	if LogError(err) {
+		sendServerError(w)
+		return
+	}
+
+	response := models.ListBatchesResponse{
+		Batches: make([]models.BatchInfo, len(batches)),
+	}
+
+	// Summarize the states of the jobs
+	for i := range batches {
+		_, jobs, err := handlerData.JobService.GetBatch(batches[i].ID)
+		if LogError(err) {
+			sendServerError(w)
+			return
+		}
+
+		jobInfos := make([]models.JobInfo, len(jobs))
+		for j := range jobs {
+			jobInfos[j] = jobs[j].ToJobInfo()
+		}
+
+		info := models.NewBatchInfo(batches[i], jobInfos)
+		info.Jobs = nil
+		response.Batches[i] = info
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", response)
+}
+
+// cancelBatch cancels all queued jobs of a batch
+func cancelBatch(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.BatchRequest
+
+	// Read request
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
+		return
+	}
+
+	batch, _, err := handlerData.JobService.GetBatch(request.BatchID)
+	if LogError(err) {
+		sendServerError(w)
+		return
+	}
+
+	if !canAccessBatch(handlerData, batch) {
+		sendResponse(w, models.ResponseError, "no such batch found", nil, http.StatusNotFound)
+		return
+	}
+
+	cancelled, err := handlerData.JobService.CancelBatch(batch.ID)
+	if LogError(err) {
+		sendServerError(w)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, fmt.Sprintf("cancelled %d jobs", cancelled), nil)
+}
+
+// Return true if the batch exists and the user is allowed to access it
+func canAccessBatch(handlerData HandlerData, batch *models.Batch) bool {
+	return batch != nil && (handlerData.User.IsAdmin() || batch.UserID == handlerData.User.ID)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import libremotebuild "github.com/RemoteBuild/LibRemotebuild"
+
+// Endpoints which are not part of libremotebuild
+const (
+	EPJobReorder     = libremotebuild.EPJob + "/reorder"
+	EPJobRerun       = libremotebuild.EPJob + "/rerun"
+	EPJobLogsArchive = libremotebuild.EPJobLogs + "/archive"
+	EPJobLogsStream  = libremotebuild.EPJobLogs + "/stream"
+
+	// Schedules
+	EPSchedule       libremotebuild.Endpoint = "/schedule"
+	EPScheduleAdd                            = EPSchedule + "/create"
+	EPScheduleDelete                         = EPSchedule + "/delete"
+	EPScheduleState                          = EPSchedule + "/state/{newState}"
+	EPSchedules                              = EPSchedule + "s"
+
+	// Batches
+	EPBatch       libremotebuild.Endpoint = "/batch"
+	EPBatchAdd                            = EPBatch + "/add"
+	EPBatchInfo                           = EPBatch + "/info"
+	EPBatchCancel                         = EPBatch + "/cancel"
+	EPBatches                             = EPBatch + "es"
+
+	// Users
+	EPUser         libremotebuild.Endpoint = "/user"
+	EPUserRole                             = EPUser + "/role"
+	EPUserDelete                           = EPUser + "/delete"
+	EPLogout                               = EPUser + "/logout"
+	EPUserPassword                         = EPUser + "/password"
+	EPUsers                                = EPUser + "s"
+
+	// Sessions
+	EPSession       libremotebuild.Endpoint = "/session"
+	EPSessionRevoke                         = EPSession + "/revoke"
+	EPSessions                              = EPSession + "s"
+
+	// API tokens
+	EPToken       libremotebuild.Endpoint = "/token"
+	EPTokenCreate                         = EPToken + "/create"
+	EPTokenRevoke                         = EPToken + "/revoke"
+	EPTokens                              = EPToken + "s"
+
+	// Server
+	EPServer      libremotebuild.Endpoint = "/server"
+	EPServerDrain                         = EPServer + "/drain"
+)
@@ -12,6 +12,9 @@ type HandlerData struct {
 	Config       *models.Config
 	Db           *gorm.DB
 	User         *models.User
+	Session      *models.LoginSession // Set if the request uses a login session
+	Token        *models.APIToken     // Set if the request uses an API token
 	JobService   *services.JobService
+	Scheduler    *services.SchedulerService
 	DockerClient *docker.Client
 }
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"os"
 	"strconv"
 	"time"
 
@@ -16,40 +18,212 @@ import (
 
 // AddJob add a job
 func addJob(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
-	var request libremotebuild.AddJobRequest
+	var request models.AddJobRequest
 
 	// Read request
 	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
 		return
 	}
 
-	// Check input
-	if len(request.Type.String()) == 0 {
-		sendResponse(w, models.ResponseError, "input missing", nil, http.StatusUnprocessableEntity)
+	options, ok := parseJobOptions(handlerData, w, request)
+	if !ok {
+		return
+	}
+	options.UserID = handlerData.User.ID
+
+	jqi, err := handlerData.JobService.Queue.AddNewJob(handlerData.Db, options)
+	if err == models.ErrDependencyNotFound {
+		sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusUnprocessableEntity)
+		return
+	}
+	if err == services.ErrQueueDraining {
+		sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusServiceUnavailable)
+		return
+	}
+	if LogError(err) {
+		sendServerError(w)
 		return
 	}
 
+	sendResponse(w, models.ResponseSuccess, "", libremotebuild.AddJobResponse{
+		ID:       jqi.JobID,
+		Position: handlerData.JobService.Queue.GetJobQueuePos(jqi),
+	})
+}
+
+// Validate an AddJobRequest and create JobOptions of it.
+// Returns false and sends an error if it's invalid
+func parseJobOptions(handlerData HandlerData, w http.ResponseWriter, request models.AddJobRequest) (services.JobOptions, bool) {
+	// Parse priority
+	priority, ok := models.ParseJobPriority(request.Priority)
+	if !ok {
+		sendResponse(w, models.ResponseError, "invalid priority", nil, http.StatusUnprocessableEntity)
+		return services.JobOptions{}, false
+	}
+
 	// Validate request build type
-	switch request.Type {
-	case libremotebuild.JobAUR:
-	default:
-		sendResponse(w, models.ResponseError, "build type not supported", "", http.StatusUnprocessableEntity)
+	if !checkBuildType(w, request.Type) {
+		return services.JobOptions{}, false
+	}
+
+	// Validate retry policy
+	if request.Retry != nil {
+		if err := request.Retry.Check(handlerData.Config.Server.Jobs.Retry.MaxAttemptsLimit); err != nil {
+			sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusUnprocessableEntity)
+			return services.JobOptions{}, false
+		}
+	}
+
+	// Validate timeout. 0 uses the default timeout
+	if request.Timeout != 0 {
+		if err := handlerData.Config.CheckBuildTimeout(time.Duration(request.Timeout)); err != nil {
+			sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusUnprocessableEntity)
+			return services.JobOptions{}, false
+		}
+	}
+
+	// Validate container limits
+	var limits models.ResourceLimits
+	if request.Limits != nil {
+		limits = *request.Limits
+		if err := limits.Check(handlerData.Config.Server.Jobs.MaxLimits); err != nil {
+			sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusUnprocessableEntity)
+			return services.JobOptions{}, false
+		}
+	}
+
+	return services.JobOptions{
+		Type:       request.Type,
+		UploadType: request.UploadType,
+		Args:       request.Args,
+		UseCcache:  !request.DisableCcache,
+		Priority:   priority,
+		DependsOn:  request.DependsOn,
+		Retry:      request.Retry,
+		Timeout:    time.Duration(request.Timeout),
+		Limits:     limits,
+		Force:      request.Force,
+
+		DependOnAnyJob: handlerData.User.IsAdmin(),
+	}, true
+}
+
+// rerunJob create a new job with the settings of an existing job
+func rerunJob(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.RerunJobRequest
+
+	// Read request
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
 		return
 	}
 
-	// Add Job to queue
-	jqi, err := handlerData.JobService.Queue.AddNewJob(handlerData.Db, request.Type, request.UploadType, request.Args, (!request.DisableCcache))
+	// Parse priority
+	priority, ok := models.ParseJobPriority(request.Priority)
+	if !ok {
+		sendResponse(w, models.ResponseError, "invalid priority", nil, http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Validate timeout. 0 keeps the timeout of the job
+	if request.Timeout != 0 {
+		if err := handlerData.Config.CheckBuildTimeout(time.Duration(request.Timeout)); err != nil {
+			sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	if !checkJobAccess(handlerData, w, request.JobID) {
+		return
+	}
+
+	jqi, err := handlerData.JobService.RerunJob(request, priority, handlerData.User.ID)
+	if err == nil && jqi == nil {
+		sendResponse(w, models.ResponseError, "no such job found", nil, http.StatusNotFound)
+		return
+	}
+	if err == models.ErrNoArgsStored || errors.Is(err, models.ErrMissingSecretArgs) {
+		sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusUnprocessableEntity)
+		return
+	}
+	if err == services.ErrQueueDraining {
+		sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusServiceUnavailable)
+		return
+	}
 	if LogError(err) {
 		sendServerError(w)
 		return
 	}
 
 	sendResponse(w, models.ResponseSuccess, "", libremotebuild.AddJobResponse{
-		ID:       jqi.ID,
+		ID:       jqi.JobID,
 		Position: handlerData.JobService.Queue.GetJobQueuePos(jqi),
 	})
 }
 
+// Return false and send an error if the job doesn't exist or
+// the user isn't allowed to access it. Admins can access all jobs
+func checkJobAccess(handlerData HandlerData, w http.ResponseWriter, jobID uint) bool {
+	if handlerData.User.IsAdmin() {
+		return true
+	}
+
+	owner, found, err := handlerData.JobService.GetJobOwner(jobID)
+	if LogError(err) {
+		sendServerError(w)
+		return false
+	}
+
+	// Don't leak the existence of jobs of other users
+	if !found || owner != handlerData.User.ID {
+		sendResponse(w, models.ResponseError, "no such job found", nil, http.StatusNotFound)
+		return false
+	}
+
+	return true
+}
+
+// Set the usernames of the owners of the jobs
+func setJobOwners(handlerData HandlerData, jobInfos []models.JobInfo) {
+	var ids []uint
+	for i := range jobInfos {
+		if jobInfos[i].UserID > 0 {
+			ids = append(ids, jobInfos[i].UserID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return
+	}
+
+	names, err := models.GetUsernames(handlerData.Db, ids)
+	if LogError(err) {
+		return
+	}
+
+	for i := range jobInfos {
+		jobInfos[i].Owner = names[jobInfos[i].UserID]
+	}
+}
+
+// Return false and send an error if
+// the build type is not supported
+func checkBuildType(w http.ResponseWriter, buildType libremotebuild.JobType) bool {
+	// Check input
+	if len(buildType.String()) == 0 {
+		sendResponse(w, models.ResponseError, "input missing", nil, http.StatusUnprocessableEntity)
+		return false
+	}
+
+	switch buildType {
+	case libremotebuild.JobAUR:
+	default:
+		sendResponse(w, models.ResponseError, "build type not supported", "", http.StatusUnprocessableEntity)
+		return false
+	}
+
+	return true
+}
+
 func jobInfo(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
 	var request libremotebuild.JobRequest
 	// Read request
@@ -57,6 +231,10 @@ func jobInfo(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkJobAccess(handlerData, w, request.JobID) {
+		return
+	}
+
 	// Get Job
 	job, err := handlerData.JobService.GetJobInfo(request.JobID)
 	if err != nil {
@@ -69,7 +247,10 @@ func jobInfo(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sendResponse(w, models.ResponseSuccess, "", job.ToJobInfo())
+	info := []models.JobInfo{job.ToJobInfo()}
+	setJobOwners(handlerData, info)
+
+	sendResponse(w, models.ResponseSuccess, "", info[0])
 }
 
 // listJobs view the queue
@@ -81,20 +262,32 @@ func listJobs(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Users only see their own jobs
+	var userID uint
+	if !handlerData.User.IsAdmin() {
+		userID = handlerData.User.ID
+	}
+
 	jobs := handlerData.JobService.Queue.GetJobs()
-	jobInfos := make([]libremotebuild.JobInfo, len(jobs))
+	jobInfos := make([]models.JobInfo, 0, len(jobs))
 
 	// Bulid JobInfos
-	for i, jobQueueItem := range jobs {
+	for _, jobQueueItem := range jobs {
+		if userID != 0 && jobQueueItem.Job.UserID != userID {
+			continue
+		}
+
 		jobQueueItem.Load(handlerData.Db, handlerData.Config)
 		job := jobQueueItem.Job
 
-		jobInfos[i] = job.ToJobInfo()
-		jobInfos[i].Position = jobQueueItem.Position
+		info := job.ToJobInfo()
+		info.Position = jobQueueItem.Position
 
 		if job.GetState() == libremotebuild.JobRunning {
-			jobInfos[i].RunningSince = jobQueueItem.RunningSince
+			info.RunningSince = jobQueueItem.RunningSince
 		}
+
+		jobInfos = append(jobInfos, info)
 	}
 
 	limit := 10
@@ -110,11 +303,11 @@ func listJobs(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
 		limit = limit - len(jobInfos)
 	}
 
-	resp := libremotebuild.ListJobsResponse{Jobs: jobInfos}
+	resp := models.ListJobsResponse{Jobs: jobInfos}
 
 	// Get old jobs
 	if limit > 0 {
-		oldJobs, err := handlerData.JobService.GetOldJobs(limit)
+		oldJobs, err := handlerData.JobService.GetOldJobs(limit, userID)
 		if err != nil && err != gorm.ErrRecordNotFound {
 			sendResponse(w, models.ResponseError, "", nil, http.StatusInternalServerError)
 			return
@@ -126,6 +319,8 @@ func listJobs(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	setJobOwners(handlerData, resp.Jobs)
+
 	// Send list
 	sendResponse(w, models.ResponseSuccess, "", resp)
 }
@@ -138,27 +333,23 @@ func cancelJob(handlerData HandlerData, w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Get Job
-	job := handlerData.JobService.Queue.FindJob(request.JobID)
-	if job == nil {
-		sendResponse(w, models.ResponseError, "no such job found", nil, http.StatusNotFound)
+	if !checkJobAccess(handlerData, w, request.JobID) {
 		return
 	}
 
 	// Cancel job
-	job.Job.Cancel()
-	job.Deleted = true
-
-	if err := job.Job.Save(); err != nil {
-		log.Info(err)
+	err := handlerData.JobService.Queue.CancelJob(request.JobID)
+	if err == services.ErrJobNotInQueue {
+		sendResponse(w, models.ResponseError, "no such job found", nil, http.StatusNotFound)
+		return
+	}
+	if LogError(err) {
+		sendServerError(w)
+		return
 	}
 
 	// send success
 	sendResponse(w, models.ResponseSuccess, "cancel successful", nil)
-
-	// Remove from Db
-	handlerData.Db.Where("job_id=?", request.JobID).Delete(&services.JobQueueItem{})
-	log.Info("Cancelled Job ", request.JobID)
 }
 
 // get logs of a job
@@ -169,6 +360,10 @@ func getLogs(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkJobAccess(handlerData, w, request.JobID) {
+		return
+	}
+
 	// Try getting requested runnig job
 	if job := handlerData.JobService.Queue.FindJob(request.JobID); job != nil {
 		// Check if container is running
@@ -208,6 +403,88 @@ func getLogs(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// reorderJob move a queued job in the queue
+func reorderJob(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.ReorderJobRequest
+	// Read request
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
+		return
+	}
+
+	if !checkJobAccess(handlerData, w, request.JobID) {
+		return
+	}
+
+	// Move job
+	err := handlerData.JobService.Queue.MoveJob(request.JobID, request.Action, request.TargetID)
+	switch err {
+	case nil:
+	case services.ErrJobNotInQueue:
+		sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusNotFound)
+		return
+	case services.ErrJobIsRunning, services.ErrInvalidMoveAction:
+		sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusUnprocessableEntity)
+		return
+	default:
+		LogError(err)
+		sendServerError(w)
+		return
+	}
+
+	jqi := handlerData.JobService.Queue.FindJob(request.JobID)
+	if jqi == nil {
+		sendResponse(w, models.ResponseError, services.ErrJobNotInQueue.Error(), nil, http.StatusNotFound)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", libremotebuild.AddJobResponse{
+		ID:       jqi.JobID,
+		Position: handlerData.JobService.Queue.GetJobQueuePos(jqi),
+	})
+}
+
+// get a page of the archived logs of a job
+func getArchivedLogs(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.ArchivedLogsRequest
+	// Read request
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
+		return
+	}
+
+	if request.Offset < 0 || request.Limit < 0 {
+		sendResponse(w, models.ResponseError, "invalid range", nil, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if !checkJobAccess(handlerData, w, request.JobID) {
+		return
+	}
+
+	path := handlerData.Config.GetLogArchivePath(request.JobID)
+	if len(path) == 0 {
+		sendResponse(w, models.ResponseError, "Log archiving is disabled", nil, http.StatusNotFound)
+		return
+	}
+
+	page, err := models.ReadLogArchive(path, request.Offset, request.Limit, request.ByLines)
+	if err != nil {
+		if os.IsNotExist(err) {
+			sendResponse(w, models.ResponseError, "No logs archived for job", nil, http.StatusNotFound)
+			return
+		}
+
+		LogError(err)
+		sendServerError(w)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", models.ArchivedLogsResponse{
+		Content: string(page.Content),
+		Next:    page.Next,
+		EOF:     page.EOF,
+	})
+}
+
 // Sets the jobs state to either paused or running
 func setState(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
 	v := mux.Vars(r)
@@ -223,6 +500,10 @@ func setState(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkJobAccess(handlerData, w, request.JobID) {
+		return
+	}
+
 	// Find Job in Queue
 	job := handlerData.JobService.Queue.FindJob(request.JobID)
 	if job == nil {
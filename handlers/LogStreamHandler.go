@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
+	"github.com/RemoteBuild/Remotebuild/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// Server-Sent event types in addition to the job events
+const (
+	eventLog = "log"
+)
+
+// How long to wait for the logs of an exited
+// build container before stopping to follow them
+const followGracePeriod = 5 * time.Second
+
+// streamLogs streams the logs, phase changes and the result
+// of a job as Server-Sent Events. The stream is limited by
+// the WriteTimeout of the webserver
+func streamLogs(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	var request libremotebuild.JobRequest
+	// Read request
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
+		return
+	}
+
+	if !checkJobAccess(handlerData, w, request.JobID) {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		sendResponse(w, models.ResponseError, "streaming not supported", nil, http.StatusInternalServerError)
+		return
+	}
+
+	jqi := handlerData.JobService.Queue.FindJob(request.JobID)
+	if jqi == nil {
+		// Job is not queued anymore, send its result only
+		job, err := handlerData.JobService.GetJobInfo(request.JobID)
+		if err != nil {
+			LogError(err)
+			sendServerError(w)
+			return
+		}
+
+		if job == nil {
+			sendResponse(w, models.ResponseError, "no such job found", nil, http.StatusNotFound)
+			return
+		}
+
+		stream := newEventStream(w, flusher)
+		stream.send(models.EventResult, job.Result)
+		return
+	}
+
+	// Subscribe before checking the state of the job. The
+	// result of a job which exits in between gets delivered
+	// as event
+	job := jqi.Job
+	events, unsubscribe := job.Subscribe()
+	defer unsubscribe()
+
+	stream := newEventStream(w, flusher)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	lines := make(chan string, 100)
+	var current *logFollower
+
+	// Follow the logs of the current build container
+	follow := func() {
+		followCtx, stop := context.WithCancel(ctx)
+		current = &logFollower{stop: stop, done: make(chan struct{})}
+
+		go func(follower *logFollower) {
+			defer close(follower.done)
+
+			// Use the request context, so lines read before
+			// stopping still get sent
+			writer := &lineWriter{ctx: ctx, lines: lines}
+			err := job.BuildJob.FollowLogs(followCtx, writer)
+			if err != nil && err != models.ErrJobNotRunning && followCtx.Err() == nil {
+				log.Warn(err)
+			}
+
+			writer.flush()
+		}(current)
+	}
+
+	// Wait for the current follower to send the remaining logs
+	finishFollowing := func() {
+		if current == nil {
+			return
+		}
+
+		follower := current
+		current = nil
+
+		grace := time.NewTimer(followGracePeriod)
+		defer grace.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case line := <-lines:
+				stream.send(eventLog, line)
+			case <-grace.C:
+				follower.stop()
+			case <-follower.done:
+				follower.stop()
+
+				// Send buffered lines
+				for {
+					select {
+					case line := <-lines:
+						stream.send(eventLog, line)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}
+
+	if job.BuildJob.State == libremotebuild.JobRunning && len(job.BuildJob.ContainerID) > 0 {
+		stream.send(models.EventPhase, string(models.PhaseBuild))
+		follow()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line := <-lines:
+			stream.send(eventLog, line)
+		case event := <-events:
+			finishFollowing()
+			stream.send(event.Type, event.Data)
+
+			switch event.Type {
+			case models.EventPhase:
+				// A new container was started
+				if event.Data == string(models.PhaseBuild) {
+					follow()
+				}
+			case models.EventResult:
+				return
+			}
+		}
+	}
+}
+
+// logFollower follows the logs of a build container
+type logFollower struct {
+	stop context.CancelFunc
+	done chan struct{} // Closed after all lines were sent
+}
+
+// eventStream writes Server-Sent Events
+type eventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	mx      sync.Mutex
+}
+
+func newEventStream(w http.ResponseWriter, flusher http.Flusher) *eventStream {
+	w.Header().Set(models.HeaderStatus, "1")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &eventStream{
+		w:       w,
+		flusher: flusher,
+	}
+}
+
+// Send an event
+func (stream *eventStream) send(event, data string) {
+	stream.mx.Lock()
+	defer stream.mx.Unlock()
+
+	fmt.Fprintf(stream.w, "event: %s\n", event)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(stream.w, "data: %s\n", line)
+	}
+	fmt.Fprint(stream.w, "\n")
+
+	stream.flusher.Flush()
+}
+
+// lineWriter splits written data into lines
+type lineWriter struct {
+	ctx   context.Context
+	lines chan<- string
+	buf   []byte
+}
+
+func (writer *lineWriter) Write(p []byte) (int, error) {
+	writer.buf = append(writer.buf, p...)
+
+	for {
+		i := strings.IndexByte(string(writer.buf), '\n')
+		if i == -1 {
+			break
+		}
+
+		if !writer.send(string(writer.buf[:i])) {
+			return 0, writer.ctx.Err()
+		}
+		writer.buf = writer.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Send remaining data
+func (writer *lineWriter) flush() {
+	if len(writer.buf) > 0 {
+		writer.send(string(writer.buf))
+		writer.buf = nil
+	}
+}
+
+func (writer *lineWriter) send(line string) bool {
+	select {
+	case writer.lines <- line:
+		return true
+	case <-writer.ctx.Done():
+		return false
+	}
+}
@@ -23,6 +23,7 @@ type Route struct {
 	Pattern     libremotebuild.Endpoint
 	HandlerFunc RouteFunction
 	HandlerType requestType
+	Permission  models.Permission
 }
 
 // HTTPMethod http method. GET, POST, DELETE, HEADER, etc...
@@ -78,6 +79,89 @@ var (
 			HandlerType: defaultRequest,
 		},
 
+		Route{
+			Name:        "Logout",
+			Pattern:     EPLogout,
+			Method:      POSTMethod,
+			HandlerFunc: logout,
+			HandlerType: sessionRequest,
+			Permission:  models.PermNone,
+		},
+		Route{
+			Name:        "Change password",
+			Pattern:     EPUserPassword,
+			Method:      POSTMethod,
+			HandlerFunc: changePassword,
+			HandlerType: sessionRequest,
+			Permission:  models.PermNone,
+		},
+		Route{
+			Name:        "List sessions",
+			Pattern:     EPSessions,
+			Method:      GetMethod,
+			HandlerFunc: listSessions,
+			HandlerType: sessionRequest,
+			Permission:  models.PermNone,
+		},
+		Route{
+			Name:        "Revoke sessions",
+			Pattern:     EPSessionRevoke,
+			Method:      POSTMethod,
+			HandlerFunc: revokeSessions,
+			HandlerType: sessionRequest,
+			Permission:  models.PermNone,
+		},
+		Route{
+			Name:        "Delete user",
+			Pattern:     EPUserDelete,
+			Method:      DeleteMethod,
+			HandlerFunc: deleteUser,
+			HandlerType: sessionRequest,
+			Permission:  models.PermAdmin,
+		},
+		Route{
+			Name:        "List users",
+			Pattern:     EPUsers,
+			Method:      GetMethod,
+			HandlerFunc: listUsers,
+			HandlerType: sessionRequest,
+			Permission:  models.PermAdmin,
+		},
+		Route{
+			Name:        "Set user role",
+			Pattern:     EPUserRole,
+			Method:      PUTMethod,
+			HandlerFunc: setUserRole,
+			HandlerType: sessionRequest,
+			Permission:  models.PermAdmin,
+		},
+
+		// API tokens
+		Route{
+			Name:        "Create API token",
+			Pattern:     EPTokenCreate,
+			Method:      PUTMethod,
+			HandlerFunc: createAPIToken,
+			HandlerType: sessionRequest,
+			Permission:  models.PermJobRead,
+		},
+		Route{
+			Name:        "List API tokens",
+			Pattern:     EPTokens,
+			Method:      GetMethod,
+			HandlerFunc: listAPITokens,
+			HandlerType: sessionRequest,
+			Permission:  models.PermJobRead,
+		},
+		Route{
+			Name:        "Revoke API token",
+			Pattern:     EPTokenRevoke,
+			Method:      DeleteMethod,
+			HandlerFunc: revokeAPIToken,
+			HandlerType: sessionRequest,
+			Permission:  models.PermJobRead,
+		},
+
 		// Job
 		Route{
 			Name:        "Add Job",
@@ -85,6 +169,55 @@ var (
 			Method:      PUTMethod,
 			HandlerFunc: addJob,
 			HandlerType: sessionRequest,
+			Permission:  models.PermJobCreate,
+		},
+		Route{
+			Name:        "Rerun Job",
+			Pattern:     EPJobRerun,
+			Method:      PUTMethod,
+			HandlerFunc: rerunJob,
+			HandlerType: sessionRequest,
+			Permission:  models.PermJobCreate,
+		},
+		Route{
+			Name:        "Add batch",
+			Pattern:     EPBatchAdd,
+			Method:      PUTMethod,
+			HandlerFunc: addBatch,
+			HandlerType: sessionRequest,
+			Permission:  models.PermJobCreate,
+		},
+		Route{
+			Name:        "Batch info",
+			Pattern:     EPBatchInfo,
+			Method:      GetMethod,
+			HandlerFunc: batchInfo,
+			HandlerType: sessionRequest,
+			Permission:  models.PermJobRead,
+		},
+		Route{
+			Name:        "Cancel batch",
+			Pattern:     EPBatchCancel,
+			Method:      POSTMethod,
+			HandlerFunc: cancelBatch,
+			HandlerType: sessionRequest,
+			Permission:  models.PermJobCreate,
+		},
+		Route{
+			Name:        "List batches",
+			Pattern:     EPBatches,
+			Method:      GetMethod,
+			HandlerFunc: listBatches,
+			HandlerType: sessionRequest,
+			Permission:  models.PermJobRead,
+		},
+		Route{
+			Name:        "Drain server",
+			Pattern:     EPServerDrain,
+			Method:      POSTMethod,
+			HandlerFunc: drainServer,
+			HandlerType: sessionRequest,
+			Permission:  models.PermAdmin,
 		},
 		Route{
 			Name:        "List jobs",
@@ -92,6 +225,7 @@ var (
 			Method:      GetMethod,
 			HandlerFunc: listJobs,
 			HandlerType: sessionRequest,
+			Permission:  models.PermJobRead,
 		},
 		Route{
 			Name:        "Cancel job",
@@ -99,6 +233,7 @@ var (
 			Method:      POSTMethod,
 			HandlerFunc: cancelJob,
 			HandlerType: sessionRequest,
+			Permission:  models.PermJobCreate,
 		},
 		Route{
 			Name:        "",
@@ -106,6 +241,23 @@ var (
 			Method:      GetMethod,
 			HandlerFunc: getLogs,
 			HandlerType: sessionRequest,
+			Permission:  models.PermJobRead,
+		},
+		Route{
+			Name:        "Archived logs",
+			Pattern:     EPJobLogsArchive,
+			Method:      GetMethod,
+			HandlerFunc: getArchivedLogs,
+			HandlerType: sessionRequest,
+			Permission:  models.PermJobRead,
+		},
+		Route{
+			Name:        "Stream logs",
+			Pattern:     EPJobLogsStream,
+			Method:      GetMethod,
+			HandlerFunc: streamLogs,
+			HandlerType: sessionRequest,
+			Permission:  models.PermJobRead,
 		},
 		Route{
 			Name:        "SetState",
@@ -113,6 +265,15 @@ var (
 			Method:      PUTMethod,
 			HandlerFunc: setState,
 			HandlerType: sessionRequest,
+			Permission:  models.PermJobCreate,
+		},
+		Route{
+			Name:        "Reorder job",
+			Pattern:     EPJobReorder,
+			Method:      PUTMethod,
+			HandlerFunc: reorderJob,
+			HandlerType: sessionRequest,
+			Permission:  models.PermAdmin,
 		},
 		Route{
 			Name:        "Job Info",
@@ -120,6 +281,41 @@ var (
 			Method:      GetMethod,
 			HandlerFunc: jobInfo,
 			HandlerType: sessionRequest,
+			Permission:  models.PermJobRead,
+		},
+
+		// Schedules
+		Route{
+			Name:        "Add schedule",
+			Pattern:     EPScheduleAdd,
+			Method:      PUTMethod,
+			HandlerFunc: addSchedule,
+			HandlerType: sessionRequest,
+			Permission:  models.PermJobCreate,
+		},
+		Route{
+			Name:        "List schedules",
+			Pattern:     EPSchedules,
+			Method:      GetMethod,
+			HandlerFunc: listSchedules,
+			HandlerType: sessionRequest,
+			Permission:  models.PermJobRead,
+		},
+		Route{
+			Name:        "Set schedule state",
+			Pattern:     EPScheduleState,
+			Method:      PUTMethod,
+			HandlerFunc: setScheduleState,
+			HandlerType: sessionRequest,
+			Permission:  models.PermJobCreate,
+		},
+		Route{
+			Name:        "Delete schedule",
+			Pattern:     EPScheduleDelete,
+			Method:      DeleteMethod,
+			HandlerFunc: deleteSchedule,
+			HandlerType: sessionRequest,
+			Permission:  models.PermJobCreate,
 		},
 
 		// Ccache
@@ -129,6 +325,7 @@ var (
 			Method:      POSTMethod,
 			HandlerFunc: clearCcache,
 			HandlerType: sessionRequest,
+			Permission:  models.PermAdmin,
 		},
 		Route{
 			Name:        "Query ccache",
@@ -136,16 +333,18 @@ var (
 			Method:      GetMethod,
 			HandlerFunc: ccacheStats,
 			HandlerType: sessionRequest,
+			Permission:  models.PermJobRead,
 		},
 	}
 )
 
 // NewRouter create new router
-func NewRouter(config *models.Config, db *gorm.DB, jobService *services.JobService) *mux.Router {
+func NewRouter(config *models.Config, db *gorm.DB, jobService *services.JobService, scheduler *services.SchedulerService) *mux.Router {
 	handlerData := HandlerData{
 		Config:     config,
 		Db:         db,
 		JobService: jobService,
+		Scheduler:  scheduler,
 	}
 
 	router := mux.NewRouter().StrictSlash(true)
@@ -154,14 +353,14 @@ func NewRouter(config *models.Config, db *gorm.DB, jobService *services.JobServi
 			Methods(string(route.Method)).
 			Path(string(route.Pattern)).
 			Name(route.Name).
-			Handler(RouteHandler(route.HandlerType, &handlerData, route.HandlerFunc, route.Name))
+			Handler(RouteHandler(route.HandlerType, route.Permission, &handlerData, route.HandlerFunc, route.Name))
 	}
 
 	return router
 }
 
 // RouteHandler logs stuff
-func RouteHandler(requestType requestType, handlerData *HandlerData, inner RouteFunction, name string) http.Handler {
+func RouteHandler(requestType requestType, permission models.Permission, handlerData *HandlerData, inner RouteFunction, name string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			err := r.Body.Close()
@@ -182,13 +381,17 @@ func RouteHandler(requestType requestType, handlerData *HandlerData, inner Route
 			return
 		}
 
+		// Use a copy for each request, validate
+		// sets the user of the request
+		data := *handlerData
+
 		// Validate request by requestType
-		if !requestType.validate(handlerData, r, w) {
+		if !requestType.validate(&data, permission, r, w) {
 			return
 		}
 
 		// Process request
-		inner(*handlerData, w, r)
+		inner(data, w, r)
 
 		// Print duration of processing
 		if needDebug {
@@ -197,29 +400,55 @@ func RouteHandler(requestType requestType, handlerData *HandlerData, inner Route
 	})
 }
 
-// Return false on error
-func (requestType requestType) validate(handlerData *HandlerData, r *http.Request, w http.ResponseWriter) bool {
+// Return false on error or if the user
+// doesn't have the required permission
+func (requestType requestType) validate(handlerData *HandlerData, permission models.Permission, r *http.Request, w http.ResponseWriter) bool {
 	switch requestType {
 	case sessionRequest:
 		{
 			authHandler := NewAuthHandler(r)
-			if len(authHandler.GetBearer()) != 64 {
-				log.Error("Invalid token len %d", len(authHandler.GetBearer()))
-				sendResponse(w, models.ResponseError, "Invalid token", http.StatusUnauthorized)
-				return false
-			}
+			token := authHandler.GetBearer()
+
+			// API tokens are limited by their scopes
+			if models.IsAPIToken(token) {
+				apiToken, err := models.GetUserFromAPIToken(handlerData.Db, token)
+				if LogError(err) || apiToken == nil {
+					sendResponse(w, models.ResponseError, "Invalid token", nil, http.StatusUnauthorized)
+					return false
+				}
 
-			user, err := models.GetUserFromSession(handlerData.Db, authHandler.GetBearer())
-			if LogError(err) || user == nil {
-				if user == nil && err == nil {
-					log.Error("Can't get user")
+				if !apiToken.HasPermission(permission) {
+					sendResponse(w, models.ResponseError, "Token scope doesn't allow this request", nil, http.StatusForbidden)
+					return false
 				}
 
-				sendResponse(w, models.ResponseError, "Invalid token", http.StatusUnauthorized)
-				return false
+				handlerData.User = apiToken.User
+				handlerData.Token = apiToken
+			} else {
+				if len(token) != 64 {
+					log.Errorf("Invalid token len %d", len(token))
+					sendResponse(w, models.ResponseError, "Invalid token", http.StatusUnauthorized)
+					return false
+				}
+
+				session, err := models.GetSession(handlerData.Db, handlerData.Config, token)
+				if LogError(err) || session == nil || session.User == nil {
+					if session == nil && err == nil {
+						log.Error("Can't get user")
+					}
+
+					sendResponse(w, models.ResponseError, "Invalid token", http.StatusUnauthorized)
+					return false
+				}
+
+				handlerData.User = session.User
+				handlerData.Session = session
 			}
 
-			handlerData.User = user
+			if !handlerData.User.HasPermission(permission) {
+				sendResponse(w, models.ResponseError, "Permission denied", nil, http.StatusForbidden)
+				return false
+			}
 		}
 	}
 
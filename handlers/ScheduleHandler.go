@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/RemoteBuild/Remotebuild/models"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// addSchedule create a new schedule
+func addSchedule(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.AddScheduleRequest
+
+	// Read request
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
+		return
+	}
+
+	// Validate request build type
+	if !checkBuildType(w, request.Type) {
+		return
+	}
+
+	// Validate cron expression
+	if _, err := models.ParseCron(request.Cron); err != nil {
+		sendResponse(w, models.ResponseError, "invalid cron expression: "+err.Error(), nil, http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Parse priority
+	priority, ok := models.ParseJobPriority(request.Priority)
+	if !ok {
+		sendResponse(w, models.ResponseError, "invalid priority", nil, http.StatusUnprocessableEntity)
+		return
+	}
+
+	schedule, err := models.NewSchedule(handlerData.Db, models.Schedule{
+		UserID:        handlerData.User.ID,
+		Name:          request.Name,
+		Cron:          request.Cron,
+		BuildType:     request.Type,
+		UploadType:    request.UploadType,
+		DisableCcache: request.DisableCcache,
+		Priority:      priority,
+	}, request.Args)
+	if LogError(err) {
+		sendServerError(w)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", schedule.ToScheduleInfo())
+}
+
+// listSchedules list all schedules of the user
+func listSchedules(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	schedules, err := handlerData.Scheduler.GetSchedules(handlerData.User.ID)
+	if LogError(err) {
+		sendServerError(w)
+		return
+	}
+
+	resp := models.ListSchedulesResponse{
+		Schedules: make([]models.ScheduleInfo, len(schedules)),
+	}
+
+	for i := range schedules {
+		resp.Schedules[i] = schedules[i].ToScheduleInfo()
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", resp)
+}
+
+// setScheduleState pause or resume a schedule
+func setScheduleState(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	v := mux.Vars(r)
+
+	var paused bool
+	switch v["newState"] {
+	case "pause":
+		paused = true
+	case "resume":
+		paused = false
+	default:
+		sendResponse(w, models.ResponseError, "Bad request", nil, http.StatusBadRequest)
+		return
+	}
+
+	schedule := getRequestedSchedule(handlerData, w, r)
+	if schedule == nil {
+		return
+	}
+
+	if LogError(handlerData.Scheduler.SetPaused(schedule, paused)) {
+		sendServerError(w)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", schedule.ToScheduleInfo())
+}
+
+// deleteSchedule delete a schedule
+func deleteSchedule(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	schedule := getRequestedSchedule(handlerData, w, r)
+	if schedule == nil {
+		return
+	}
+
+	if LogError(handlerData.Scheduler.DeleteSchedule(schedule)) {
+		sendServerError(w)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, "delete successful", nil)
+}
+
+// Read a ScheduleRequest and return the requested
+// schedule. Returns nil and sends an error on failure
+func getRequestedSchedule(handlerData HandlerData, w http.ResponseWriter, r *http.Request) *models.Schedule {
+	var request models.ScheduleRequest
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
+		return nil
+	}
+
+	schedule, err := handlerData.Scheduler.GetSchedule(handlerData.User.ID, request.ID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			sendResponse(w, models.ResponseError, "no such schedule found", nil, http.StatusNotFound)
+		} else {
+			LogError(err)
+			sendServerError(w)
+		}
+
+		return nil
+	}
+
+	return schedule
+}
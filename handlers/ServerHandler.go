@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/RemoteBuild/Remotebuild/models"
+)
+
+// drainServer stops accepting new jobs. The server
+// shuts down after the running jobs are done
+func drainServer(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	handlerData.JobService.Drain()
+	sendResponse(w, models.ResponseSuccess, "draining", nil)
+}
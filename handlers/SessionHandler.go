@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/RemoteBuild/Remotebuild/models"
+)
+
+// logout revokes the current session
+func logout(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	if !requireLogin(handlerData, w) {
+		return
+	}
+
+	err := models.RevokeSession(handlerData.Db, handlerData.User.ID, handlerData.Session.ID)
+	if err != nil && err != models.ErrSessionNotFound {
+		LogError(err)
+		sendServerError(w)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", nil)
+}
+
+// listSessions lists the active sessions of the user
+func listSessions(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	if !requireLogin(handlerData, w) {
+		return
+	}
+
+	sessions, err := models.GetSessions(handlerData.Db, handlerData.User.ID)
+	if LogError(err) {
+		sendServerError(w)
+		return
+	}
+
+	response := models.ListSessionsResponse{
+		Sessions: []models.SessionInfo{},
+	}
+
+	for i := range sessions {
+		if sessions[i].IsExpired(handlerData.Config) {
+			continue
+		}
+
+		response.Sessions = append(response.Sessions, models.SessionInfo{
+			ID:        sessions[i].ID,
+			MachineID: sessions[i].MachineID,
+			Created:   sessions[i].CreatedAt,
+			LastUsed:  sessions[i].LastUsed,
+			Requests:  sessions[i].Requests,
+			Current:   sessions[i].ID == handlerData.Session.ID,
+		})
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", response)
+}
+
+// revokeSessions revokes one or all other sessions of the user
+func revokeSessions(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	if !requireLogin(handlerData, w) {
+		return
+	}
+
+	var request models.RevokeSessionRequest
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
+		return
+	}
+
+	if !request.All {
+		err := models.RevokeSession(handlerData.Db, handlerData.User.ID, request.ID)
+		if err == models.ErrSessionNotFound {
+			sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusNotFound)
+			return
+		}
+		if LogError(err) {
+			sendServerError(w)
+			return
+		}
+
+		sendResponse(w, models.ResponseSuccess, "", nil)
+		return
+	}
+
+	// Keep the current session
+	res := handlerData.Db.Unscoped().
+		Where("user_id = ? AND id != ?", handlerData.User.ID, handlerData.Session.ID).
+		Delete(&models.LoginSession{})
+	if LogError(res.Error) {
+		sendServerError(w)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, fmt.Sprintf("revoked %d sessions", res.RowsAffected), nil)
+}
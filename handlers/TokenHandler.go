@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/RemoteBuild/Remotebuild/models"
+)
+
+// Return false and send an error if the request doesn't use a login
+// session. API tokens can't be used to manage tokens and sessions
+func requireLogin(handlerData HandlerData, w http.ResponseWriter) bool {
+	if handlerData.Session == nil {
+		sendResponse(w, models.ResponseError, "Login session required", nil, http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// createAPIToken creates a named API token for the user
+func createAPIToken(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	if !requireLogin(handlerData, w) {
+		return
+	}
+
+	var request models.CreateTokenRequest
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
+		return
+	}
+
+	request.Name = strings.TrimSpace(request.Name)
+	if len(request.Name) == 0 || len(request.Name) > 100 {
+		sendResponse(w, models.ResponseError, "invalid name", nil, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if request.Expires < 0 {
+		sendResponse(w, models.ResponseError, "invalid expiry", nil, http.StatusUnprocessableEntity)
+		return
+	}
+
+	_, token, err := models.NewAPIToken(handlerData.Db, handlerData.User, request.Name, request.Scopes, request.Expires)
+	switch err {
+	case nil:
+	case models.ErrInvalidScope, models.ErrTokenNameTaken:
+		sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusUnprocessableEntity)
+		return
+	case models.ErrScopeNotAllowed:
+		sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusForbidden)
+		return
+	default:
+		LogError(err)
+		sendServerError(w)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", models.CreateTokenResponse{
+		Token: token,
+	})
+}
+
+// listAPITokens lists the API tokens of the user
+func listAPITokens(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	if !requireLogin(handlerData, w) {
+		return
+	}
+
+	tokens, err := models.GetAPITokens(handlerData.Db, handlerData.User.ID)
+	if LogError(err) {
+		sendServerError(w)
+		return
+	}
+
+	response := models.ListTokensResponse{
+		Tokens: make([]models.TokenInfo, len(tokens)),
+	}
+
+	for i := range tokens {
+		response.Tokens[i] = models.TokenInfo{
+			Name:     tokens[i].Name,
+			Scopes:   tokens[i].GetScopes(),
+			Created:  tokens[i].CreatedAt,
+			Expires:  tokens[i].ExpiresAt,
+			LastUsed: tokens[i].LastUsed,
+		}
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", response)
+}
+
+// revokeAPIToken deletes an API token of the user
+func revokeAPIToken(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	if !requireLogin(handlerData, w) {
+		return
+	}
+
+	var request models.TokenRequest
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
+		return
+	}
+
+	err := models.RevokeAPIToken(handlerData.Db, handlerData.User.ID, request.Name)
+	if err == models.ErrTokenNotFound {
+		sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusNotFound)
+		return
+	}
+	if LogError(err) {
+		sendServerError(w)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", nil)
+}
@@ -2,10 +2,10 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
 	"github.com/RemoteBuild/Remotebuild/models"
-	"github.com/JojiiOfficial/gaw"
 )
 
 //Login login handler
@@ -24,10 +24,10 @@ func Login(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
 
 	user := models.User{
 		Username: request.Username,
-		Password: gaw.SHA512(request.Username + request.Password),
+		Password: request.Password,
 	}
 
-	session, err := user.Login(handlerData.Db, request.MachineID)
+	session, err := user.Login(handlerData.Db, handlerData.Config, request.MachineID)
 	if err != nil {
 		sendResponse(w, models.ResponseError, "Invalid credentials", nil)
 		return
@@ -76,3 +76,127 @@ func Register(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
 
 	sendResponse(w, models.ResponseSuccess, "success", nil, http.StatusOK)
 }
+
+// listUsers lists all users and their roles
+func listUsers(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	users, err := models.GetUsers(handlerData.Db)
+	if LogError(err) {
+		sendServerError(w)
+		return
+	}
+
+	response := models.ListUsersResponse{
+		Users: make([]models.UserInfo, len(users)),
+	}
+
+	for i := range users {
+		response.Users[i] = models.UserInfo{
+			ID:       users[i].ID,
+			Username: users[i].Username,
+			Role:     users[i].GetRoleName(),
+			Created:  users[i].CreatedAt,
+		}
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", response)
+}
+
+// setUserRole changes the role of a user
+func setUserRole(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.SetRoleRequest
+
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
+		return
+	}
+
+	role, ok := models.ParseRole(request.Role)
+	if !ok {
+		sendResponse(w, models.ResponseError, "invalid role", nil, http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Prevent admins from locking themselves out
+	if strings.ToLower(request.Username) == handlerData.User.GetUsername() {
+		sendResponse(w, models.ResponseError, "can't change own role", nil, http.StatusUnprocessableEntity)
+		return
+	}
+
+	err := models.SetRole(handlerData.Db, request.Username, role)
+	if err == models.ErrUserNotFound {
+		sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusNotFound)
+		return
+	}
+	if LogError(err) {
+		sendServerError(w)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", nil)
+}
+
+// deleteUser deletes a user and kills all of its sessions
+func deleteUser(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	var request models.UserRequest
+
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
+		return
+	}
+
+	if strings.ToLower(request.Username) == handlerData.User.GetUsername() {
+		sendResponse(w, models.ResponseError, "can't delete own user", nil, http.StatusUnprocessableEntity)
+		return
+	}
+
+	err := models.DeleteUser(handlerData.Db, request.Username)
+	if err == models.ErrUserNotFound {
+		sendResponse(w, models.ResponseError, err.Error(), nil, http.StatusNotFound)
+		return
+	}
+	if LogError(err) {
+		sendServerError(w)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", nil)
+}
+
+// changePassword changes the password of the user
+// and revokes all other sessions
+func changePassword(handlerData HandlerData, w http.ResponseWriter, r *http.Request) {
+	if !requireLogin(handlerData, w) {
+		return
+	}
+
+	var request models.ChangePasswordRequest
+
+	if !readRequestLimited(w, r, &request, handlerData.Config.Webserver.MaxRequestBodyLength) {
+		return
+	}
+
+	if len(request.NewPassword) == 0 {
+		sendResponse(w, models.ResponseError, "input missing", nil, http.StatusUnprocessableEntity)
+		return
+	}
+
+	user := handlerData.User
+	if ok, _ := user.CheckPassword(request.OldPassword, handlerData.Config.Server.PasswordCost); !ok {
+		sendResponse(w, models.ResponseError, "Invalid credentials", nil, http.StatusForbidden)
+		return
+	}
+
+	if LogError(user.SetPassword(handlerData.Db, request.NewPassword, handlerData.Config.Server.PasswordCost)) {
+		sendServerError(w)
+		return
+	}
+
+	// Keep the current session
+	err := handlerData.Db.Unscoped().
+		Where("user_id = ? AND id != ?", user.ID, handlerData.Session.ID).
+		Delete(&models.LoginSession{}).Error
+	if LogError(err) {
+		sendServerError(w)
+		return
+	}
+
+	sendResponse(w, models.ResponseSuccess, "", nil)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/RemoteBuild/Remotebuild/constants"
@@ -32,6 +33,70 @@ var (
 	serverCmd      = app.Command("server", "Commands for the server")
 	serverCmdStart = serverCmd.Command("start", "Start the server")
 
+	// User commands
+	userCmd = app.Command("user", "Commands for users")
+	// User create
+	userCmdCreate          = userCmd.Command("create", "Create a user")
+	userCmdCreateName      = userCmdCreate.Arg("username", "Name of the user").Required().String()
+	userCmdCreatePass      = userCmdCreate.Flag("password", "Password of the user").Envar(getEnVar(EnVarPassword)).String()
+	userCmdCreatePassStdin = userCmdCreate.Flag("password-stdin", "Read the password from stdin").Bool()
+	userCmdCreateRole      = userCmdCreate.Flag("role", "Role of the user").Default("builder").Enum(models.RoleNames()...)
+	// User delete
+	userCmdDelete     = userCmd.Command("delete", "Delete a user and kill its sessions")
+	userCmdDeleteName = userCmdDelete.Arg("username", "Name of the user").Required().String()
+	userCmdDeleteYes  = userCmdDelete.Flag("yes", "Don't ask for confirmation").Short('y').Bool()
+	// User list
+	userCmdList = userCmd.Command("list", "List all users")
+	// User passwd
+	userCmdPasswd          = userCmd.Command("passwd", "Change the password of a user")
+	userCmdPasswdName      = userCmdPasswd.Arg("username", "Name of the user").Required().String()
+	userCmdPasswdPass      = userCmdPasswd.Flag("password", "New password of the user").Envar(getEnVar(EnVarPassword)).String()
+	userCmdPasswdPassStdin = userCmdPasswd.Flag("password-stdin", "Read the password from stdin").Bool()
+	// User set-role
+	userCmdSetRole     = userCmd.Command("set-role", "Set the role of a user")
+	userCmdSetRoleName = userCmdSetRole.Arg("username", "Name of the user").Required().String()
+	userCmdSetRoleRole = userCmdSetRole.Arg("role", "New role of the user").Required().Enum(models.RoleNames()...)
+
+	// Job commands. They work on the stored queue and
+	// aren't noticed by a running server
+	jobCmd = app.Command("job", "Commands for jobs. Stop the server before changing jobs")
+	// Job list
+	jobCmdList      = jobCmd.Command("list", "List queued jobs")
+	jobCmdListAll   = jobCmdList.Flag("all", "List the latest jobs instead of the queue").Short('a').Bool()
+	jobCmdListLimit = jobCmdList.Flag("limit", "Max count of jobs to list with --all").Short('n').Default("20").Int()
+	// Job show
+	jobCmdShow   = jobCmd.Command("show", "Show details of a job")
+	jobCmdShowID = jobCmdShow.Arg("id", "ID of the job").Required().Uint()
+	// Job cancel
+	jobCmdCancel     = jobCmd.Command("cancel", "Cancel a queued or running job")
+	jobCmdCancelID   = jobCmdCancel.Arg("id", "ID of the job").Required().Uint()
+	jobCmdCancelFail = jobCmdCancel.Flag("fail", "Mark the job as failed instead of cancelled").Bool()
+	// Job purge
+	jobCmdPurge    = jobCmd.Command("purge", "Remove all jobs from the queue. Running jobs are marked as failed")
+	jobCmdPurgeYes = jobCmdPurge.Flag("yes", "Don't ask for confirmation").Short('y').Bool()
+	// Job logs
+	jobCmdLogs   = jobCmd.Command("logs", "Print the logs of a job")
+	jobCmdLogsID = jobCmdLogs.Arg("id", "ID of the job").Required().Uint()
+
+	// Db commands
+	dbCmd = app.Command("db", "Commands for the database")
+	// Db migrate
+	dbCmdMigrate = dbCmd.Command("migrate", "Apply pending migrations")
+	// Db status
+	dbCmdStatus = dbCmd.Command("status", "Show the schema version and migrations")
+	// Db rollback
+	dbCmdRollback      = dbCmd.Command("rollback", "Revert the latest migrations")
+	dbCmdRollbackSteps = dbCmdRollback.Flag("steps", "Count of migrations to revert").Short('n').Default("1").Int()
+	dbCmdRollbackYes   = dbCmdRollback.Flag("yes", "Don't ask for confirmation").Short('y').Bool()
+	// Db export
+	dbCmdExport         = dbCmd.Command("export", "Export the database into a portable archive")
+	dbCmdExportFile     = dbCmdExport.Arg("file", "Archive to create").Required().String()
+	dbCmdExportSessions = dbCmdExport.Flag("sessions", "Include login sessions").Bool()
+	dbCmdExportSecrets  = dbCmdExport.Flag("include-secrets", "Include secret args of unfinished jobs in plain text").Bool()
+	// Db import
+	dbCmdImport     = dbCmd.Command("import", "Import an archive into an empty database")
+	dbCmdImportFile = dbCmdImport.Arg("file", "Archive to import").Required().ExistingFile()
+
 	// Config commands
 	// Config create
 	configCmd           = app.Command("config", "Commands for the config file")
@@ -52,6 +117,7 @@ const (
 	EnVarLogLevel   = "LOG_LEVEL"
 	EnVarNoColor    = "NO_COLOR"
 	EnVarConfigFile = "CONFIG"
+	EnVarPassword   = "PASSWORD"
 )
 
 // Return the variable using the server prefix
@@ -107,8 +173,12 @@ func main() {
 
 		var err error
 
-		// Connect db
-		db, err = storage.ConnectToDatabase(config)
+		// Connect db. Db commands handle migrations themselves
+		if strings.HasPrefix(parsed, dbCmd.FullCommand()+" ") {
+			db, err = storage.OpenDatabase(config)
+		} else {
+			db, err = storage.ConnectToDatabase(config)
+		}
 		if err != nil {
 			log.Fatalln(err)
 			return
@@ -126,6 +196,69 @@ func main() {
 		{
 			startAPI()
 		}
+	// User ----------------------
+	case userCmdCreate.FullCommand():
+		{
+			createUser(*userCmdCreateName, *userCmdCreatePass, *userCmdCreatePassStdin, *userCmdCreateRole)
+		}
+	case userCmdDelete.FullCommand():
+		{
+			deleteUser(*userCmdDeleteName, *userCmdDeleteYes)
+		}
+	case userCmdList.FullCommand():
+		{
+			listUsers()
+		}
+	case userCmdPasswd.FullCommand():
+		{
+			changePassword(*userCmdPasswdName, *userCmdPasswdPass, *userCmdPasswdPassStdin)
+		}
+	case userCmdSetRole.FullCommand():
+		{
+			setUserRole(*userCmdSetRoleName, *userCmdSetRoleRole)
+		}
+	// Job -----------------------
+	case jobCmdList.FullCommand():
+		{
+			listJobs(*jobCmdListAll, *jobCmdListLimit)
+		}
+	case jobCmdShow.FullCommand():
+		{
+			showJob(*jobCmdShowID)
+		}
+	case jobCmdCancel.FullCommand():
+		{
+			cancelJob(*jobCmdCancelID, *jobCmdCancelFail)
+		}
+	case jobCmdPurge.FullCommand():
+		{
+			purgeQueue(*jobCmdPurgeYes)
+		}
+	case jobCmdLogs.FullCommand():
+		{
+			printJobLogs(*jobCmdLogsID)
+		}
+	// Db ------------------------
+	case dbCmdMigrate.FullCommand():
+		{
+			migrateDB()
+		}
+	case dbCmdStatus.FullCommand():
+		{
+			dbStatus()
+		}
+	case dbCmdRollback.FullCommand():
+		{
+			rollbackDB(*dbCmdRollbackSteps, *dbCmdRollbackYes)
+		}
+	case dbCmdExport.FullCommand():
+		{
+			exportDB(*dbCmdExportFile, *dbCmdExportSessions, *dbCmdExportSecrets)
+		}
+	case dbCmdImport.FullCommand():
+		{
+			importDB(*dbCmdImportFile)
+		}
 	// Config --------------------
 	case configCmdCreate.FullCommand():
 		{
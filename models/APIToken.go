@@ -0,0 +1,209 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/JojiiOfficial/gaw"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// APITokenPrefix prefix of all API tokens. Used to
+// distinguish them from login session tokens
+const APITokenPrefix = "rbt_"
+
+// apiTokenRandomLength length of the random part of an API token
+const apiTokenRandomLength = 48
+
+// Scopes of API tokens
+const (
+	ScopeJobRead   = "job:read"
+	ScopeJobCreate = "job:create"
+	ScopeAdmin     = "admin"
+)
+
+// scopePermissions permissions granted by the scopes
+var scopePermissions = map[string]Permission{
+	ScopeJobRead:   PermJobRead,
+	ScopeJobCreate: PermJobCreate,
+	ScopeAdmin:     PermAdmin,
+}
+
+// Errors of API tokens
+var (
+	ErrInvalidScope    = errors.New("invalid scope")
+	ErrScopeNotAllowed = errors.New("scope exceeds the role of the user")
+	ErrTokenNameTaken  = errors.New("a token with this name exists already")
+	ErrTokenNotFound   = errors.New("token not found")
+)
+
+// APIToken a named token with limited scopes
+// for accessing the API without logging in
+type APIToken struct {
+	gorm.Model
+	User      *User `gorm:"association_autoupdate:false;association_autocreate:false"`
+	UserID    uint  `sql:"index"`
+	Name      string
+	TokenHash string `sql:"index"` // Only the hash of the token is stored
+	Scopes    string // Comma separated scopes
+	ExpiresAt *time.Time
+	LastUsed  *time.Time
+}
+
+// NewAPIToken creates a new API token for the user. Returns the
+// token itself which is only available at creation time
+func NewAPIToken(db *gorm.DB, user *User, name string, scopes []string, expires time.Duration) (*APIToken, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", ErrInvalidScope
+	}
+
+	// Tokens can't grant more than the role of the user
+	for _, scope := range scopes {
+		permission, ok := scopePermissions[scope]
+		if !ok {
+			return nil, "", ErrInvalidScope
+		}
+
+		if !user.HasPermission(permission) {
+			return nil, "", ErrScopeNotAllowed
+		}
+	}
+
+	var count int64
+	if err := db.Model(&APIToken{}).Where("user_id = ? AND name = ?", user.ID, name).Count(&count).Error; err != nil {
+		return nil, "", err
+	}
+	if count > 0 {
+		return nil, "", ErrTokenNameTaken
+	}
+
+	random, err := gaw.GenRandString(apiTokenRandomLength)
+	if err != nil {
+		return nil, "", err
+	}
+	token := APITokenPrefix + random
+
+	apiToken := &APIToken{
+		UserID:    user.ID,
+		Name:      name,
+		TokenHash: HashToken(token),
+		Scopes:    strings.Join(scopes, ","),
+	}
+
+	if expires > 0 {
+		expiresAt := time.Now().Add(expires)
+		apiToken.ExpiresAt = &expiresAt
+	}
+
+	if err = db.Create(apiToken).Error; err != nil {
+		return nil, "", err
+	}
+
+	return apiToken, token, nil
+}
+
+// IsAPIToken returns true if the token is an API token
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// HashToken returns the hash of a token used for storing it
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// GetUserFromAPIToken returns the API token and its user. Returns
+// nil if the token doesn't exist or is expired
+func GetUserFromAPIToken(db *gorm.DB, token string) (*APIToken, error) {
+	var apiToken APIToken
+	err := db.Model(&APIToken{}).
+		Where("token_hash = ?", HashToken(token)).
+		Preload("User").
+		First(&apiToken).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if apiToken.IsExpired() || apiToken.User == nil {
+		return nil, nil
+	}
+
+	// Track usage
+	now := time.Now()
+	apiToken.LastUsed = &now
+	if err = db.Model(&apiToken).UpdateColumn("last_used", now).Error; err != nil {
+		log.Error(err)
+	}
+
+	return &apiToken, nil
+}
+
+// GetAPITokens returns all API tokens of a user
+func GetAPITokens(db *gorm.DB, userID uint) ([]APIToken, error) {
+	var tokens []APIToken
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// RevokeAPIToken deletes the API token of the user with the given name
+func RevokeAPIToken(db *gorm.DB, userID uint, name string) error {
+	res := db.Unscoped().Where("user_id = ? AND name = ?", userID, name).Delete(&APIToken{})
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return ErrTokenNotFound
+	}
+
+	return nil
+}
+
+// DeleteExpiredAPITokens deletes all expired API tokens
+func DeleteExpiredAPITokens(db *gorm.DB) (int64, error) {
+	res := db.Unscoped().Where("expires_at < ?", time.Now()).Delete(&APIToken{})
+	return res.RowsAffected, res.Error
+}
+
+// IsExpired returns true if the token is expired
+func (token *APIToken) IsExpired() bool {
+	return token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt)
+}
+
+// GetScopes returns the scopes of the token
+func (token *APIToken) GetScopes() []string {
+	if len(token.Scopes) == 0 {
+		return nil
+	}
+
+	return strings.Split(token.Scopes, ",")
+}
+
+// HasPermission returns true if a scope of the token grants the
+// permission. Each scope includes all permissions below it
+func (token *APIToken) HasPermission(permission Permission) bool {
+	for _, scope := range token.GetScopes() {
+		if permission <= scopePermissions[scope] {
+			return true
+		}
+	}
+
+	return permission == PermNone
+}
+
+// ScopeNames returns the names of all scopes
+func ScopeNames() []string {
+	return []string{ScopeJobRead, ScopeJobCreate, ScopeAdmin}
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAPITokenHasPermission(t *testing.T) {
+	token := APIToken{Scopes: ScopeJobCreate}
+
+	if !token.HasPermission(PermJobRead) || !token.HasPermission(PermJobCreate) {
+		t.Error("Expected job:create to grant reading and creating jobs")
+	}
+
+	if token.HasPermission(PermAdmin) {
+		t.Error("Expected job:create not to grant admin permission")
+	}
+
+	token.Scopes = ScopeJobRead
+	if token.HasPermission(PermJobCreate) {
+		t.Error("Expected job:read not to grant creating jobs")
+	}
+}
+
+func TestAPITokenIsExpired(t *testing.T) {
+	var token APIToken
+	if token.IsExpired() {
+		t.Error("Tokens without expiry must not expire")
+	}
+
+	past := time.Now().Add(-time.Minute)
+	token.ExpiresAt = &past
+	if !token.IsExpired() {
+		t.Error("Expected token to be expired")
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Batch a group of jobs submitted together
+type Batch struct {
+	gorm.Model
+	UserID uint `sql:"index"` // User who created the batch
+}
+
+// GetBatchJobs returns all jobs of a batch
+func GetBatchJobs(db *gorm.DB, batchID uint) ([]Job, error) {
+	var jobs []Job
+
+	err := db.Model(&Job{}).
+		Preload("BuildJob").
+		Preload("UploadJob").
+		Where("batch_id=?", batchID).
+		Order("id").
+		Find(&jobs).Error
+
+	return jobs, err
+}
+
+// NewBatchInfo creates info for a batch and its jobs
+func NewBatchInfo(batch Batch, jobs []JobInfo) BatchInfo {
+	info := BatchInfo{
+		ID:      batch.ID,
+		Created: batch.CreatedAt,
+		States:  make(map[string]int),
+		Jobs:    jobs,
+	}
+
+	for _, job := range jobs {
+		info.States[job.StateName]++
+	}
+
+	return info
+}
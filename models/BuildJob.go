@@ -1,8 +1,12 @@
 package models
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,18 +23,45 @@ type BuildJob struct {
 	State libremotebuild.JobState // Build state
 	Type  libremotebuild.JobType  // Type of job
 
-	Image     string // Dockerimage to run
-	UseCcache bool   // use ccahe to improve build speed
-
-	cancelChan  chan bool `gorm:"-"` // Cancel chan
-	ContainerID string    `gorm:"-"`
-	Config      *Config   `gorm:"-"`
+	Image     string        // Dockerimage to run
+	UseCcache bool          // use ccahe to improve build speed
+	Timeout   time.Duration // Max duration of the build. 0 for no timeout
+	LimitData string        // Container limits as json
+
+	Limits      ResourceLimits `gorm:"-"` // Container limits
+	cancelChan  chan bool      `gorm:"-"` // Cancel chan
+	ContainerID string         `gorm:"-"`
+	JobID       uint           `gorm:"-"` // ID of the job, used to label the container
+	reattach    bool           `gorm:"-"` // Use ContainerID instead of creating a new container
+	LogFile     string         `gorm:"-"` // File to archive the logs in
+	events      *eventHub      `gorm:"-"` // Events of the job
+	onStage     stageFunc      `gorm:"-"` // Records stage changes of the job
+	Config      *Config        `gorm:"-"`
 }
 
+// stageFunc records a stage change of a job
+type stageFunc func(stage JobStage, reason string)
+
+// Labels of build containers
+const (
+	ContainerLabelJob      = "remotebuild.job"
+	ContainerLabelBuildJob = "remotebuild.buildjob"
+)
+
 // BuildResult result of a bulid
 type BuildResult struct {
 	resinfo *ResInfo
 	Error   error
+	Phase   JobPhase // Phase the error occurred in
+}
+
+// GetPhase returns the phase the build failed in
+func (buildResult BuildResult) GetPhase() JobPhase {
+	if len(buildResult.Phase) == 0 {
+		return PhaseBuild
+	}
+
+	return buildResult.Phase
 }
 
 // NewBuildJob create new BuildJob
@@ -39,6 +70,15 @@ func NewBuildJob(db *gorm.DB, config *Config, buildJob BuildJob, image string, u
 	buildJob.Image = image
 	buildJob.Config = config
 	buildJob.UseCcache = useCcache && config.IsCcacheDirValid()
+
+	// Use default timeout if not set
+	if buildJob.Timeout == 0 {
+		buildJob.Timeout = config.Server.Jobs.BuildTimeout
+	}
+
+	// Override default limits with limits of the job
+	buildJob.Limits = config.GetLimits(buildJob.Type).Merge(buildJob.Limits)
+	buildJob.LimitData = buildJob.Limits.String()
 	buildJob.cancelChan = make(chan bool, 1)
 
 	// Connect to docker
@@ -62,6 +102,13 @@ func (buildJob *BuildJob) Init() error {
 		buildJob.cancelChan = make(chan bool, 1)
 	}
 
+	// Load limits
+	if buildJob.Limits.IsEmpty() && len(buildJob.LimitData) > 0 {
+		if err := json.Unmarshal([]byte(buildJob.LimitData), &buildJob.Limits); err != nil {
+			return err
+		}
+	}
+
 	// Connect to docker
 	return buildJob.connectDocker()
 }
@@ -74,6 +121,11 @@ func (buildJob *BuildJob) Run(dataDir string, argParser *ArgParser) (*BuildResul
 		return &BuildResult{Error: err}, nil
 	}
 
+	// cancel doesn't signal builds which aren't running yet
+	if buildJob.State == libremotebuild.JobCancelled {
+		return &BuildResult{Error: ErrorJobCancelled}, nil
+	}
+
 	log.Debug("Run BuildJob ", buildJob.ID)
 	buildJob.State = libremotebuild.JobRunning
 
@@ -126,41 +178,89 @@ func (buildJob *BuildJob) build(dataDir string, argParser *ArgParser) (*BuildRes
 		return &BuildResult{Error: err}, nil
 	}
 
-	// Pull image if neccessary
-	if err := buildJob.pullImage(buildJob.Image); err != nil {
-		return &BuildResult{Error: err}, nil
-	}
+	// Reuse the container of a previous server run
+	containerID := buildJob.ContainerID
+	reattached := buildJob.reattach
+	buildJob.reattach = false
+
+	if !reattached {
+		// Pull image if neccessary
+		buildJob.enterStage(StagePulling, "")
+		buildJob.events.publish(EventPhase, string(PhasePull))
+		if err := buildJob.pullImage(buildJob.Image); err != nil {
+			return &BuildResult{Error: err, Phase: PhasePull}, nil
+		}
 
-	// Create container
-	container, err := buildJob.getContainer(dataDir, envars)
-	if err != nil {
-		return &BuildResult{Error: err}, nil
+		// Create container
+		container, err := buildJob.getContainer(dataDir, envars)
+		if err != nil {
+			return &BuildResult{Error: err}, nil
+		}
+		containerID = container.ID
 	}
 
+	// Remove container afterwards
+	defer buildJob.removeContainer(containerID)
+
 	start := time.Now()
+	var logsSince int64
 
-	// Start container
-	if err = buildJob.StartContainer(container.ID, &docker.HostConfig{}); err != nil {
-		return &BuildResult{Error: err}, nil
+	if reattached {
+		log.Infof("BuildJob %d: reattached to container %s", buildJob.ID, containerID)
+
+		// Use the real start of the build
+		if container, err := buildJob.InspectContainerWithOptions(docker.InspectContainerOptions{ID: containerID}); err == nil {
+			start = container.State.StartedAt
+		}
+
+		// Don't archive logs twice
+		if stat, err := os.Stat(buildJob.LogFile); err == nil {
+			logsSince = stat.ModTime().Unix()
+		}
+	} else {
+		// Start container
+		if err = buildJob.StartContainer(containerID, &docker.HostConfig{}); err != nil {
+			return &BuildResult{Error: err}, nil
+		}
+	}
+	if reattached {
+		buildJob.enterStage(StageBuilding, "Reattached after server restart")
+	} else {
+		buildJob.enterStage(StageBuilding, "")
 	}
+	buildJob.events.publish(EventPhase, string(PhaseBuild))
+
+	// Archive logs
+	archiveDone := buildJob.archiveLogs(containerID, logsSince)
 
 	// Wait until building is done
-	n, err := buildJob.WaitContainer(container.ID)
+	n, err := buildJob.waitContainer(containerID)
+	duration := time.Since(start)
+
+	// Wait for the logs to be written
+	select {
+	case <-archiveDone:
+	case <-time.After(10 * time.Second):
+		log.Warn("Archiving logs didn't finish in time")
+	}
 	if err != nil {
-		return &BuildResult{Error: err}, nil
+		return &BuildResult{Error: err}, &duration
 	}
 
-	duration := time.Since(start)
-
 	// No Container should be assigned
 	// to this job anymore
 	buildJob.ContainerID = ""
 
 	// Check container exit code
 	if n != 0 {
+		if buildJob.wasOOMKilled(containerID) {
+			return &BuildResult{Error: ErrorOutOfMemory}, &duration
+		}
+
 		return &BuildResult{Error: ErrorNonZeroExit}, &duration
 	}
 
+	buildJob.enterStage(StageCollecting, "")
 	resInfo, err := ParseResInfo(dataDir, GetResInfoPath(dataDir), buildJob.ID)
 	if err != nil || resInfo == nil {
 		return &BuildResult{Error: err}, &duration
@@ -174,6 +274,27 @@ func (buildJob *BuildJob) build(dataDir string, argParser *ArgParser) (*BuildRes
 	}, &duration
 }
 
+// Wait for the container to exit. Stops the container and
+// returns ErrorJobTimedOut if the build exceeded its timeout
+func (buildJob *BuildJob) waitContainer(containerID string) (int, error) {
+	if buildJob.Timeout <= 0 {
+		return buildJob.WaitContainer(containerID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), buildJob.Timeout)
+	defer cancel()
+
+	n, err := buildJob.WaitContainerWithContext(containerID, ctx)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		log.Infof("BuildJob %d exceeded its timeout of %s", buildJob.ID, buildJob.Timeout)
+		buildJob.Stop()
+		buildJob.State = JobTimedOut
+		return n, ErrorJobTimedOut
+	}
+
+	return n, err
+}
+
 func (buildJob *BuildJob) getContainer(dataDir string, env []string) (*docker.Container, error) {
 	// Set CCACHE environment variables
 	if buildJob.UseCcache {
@@ -216,17 +337,28 @@ func (buildJob *BuildJob) getContainer(dataDir string, env []string) (*docker.Co
 		})
 	}
 
+	// Containers are removed after the build, since
+	// AutoRemove makes inspecting exited containers impossible
+	hostConfig := &docker.HostConfig{
+		Mounts: mounts,
+	}
+
+	// Apply resource limits
+	if err := buildJob.Limits.Apply(hostConfig); err != nil {
+		return nil, err
+	}
+
 	// Create container
 	container, err := buildJob.CreateContainer(docker.CreateContainerOptions{
 		Config: &docker.Config{
 			Image: buildJob.Image,
 			Env:   env,
+			Labels: map[string]string{
+				ContainerLabelJob:      strconv.FormatUint(uint64(buildJob.JobID), 10),
+				ContainerLabelBuildJob: strconv.FormatUint(uint64(buildJob.ID), 10),
+			},
 		},
-		HostConfig: &docker.HostConfig{
-			Mounts: mounts,
-			// Autodelete container afterwards
-			AutoRemove: !buildJob.Config.Server.KeepBuildContainer,
-		},
+		HostConfig: hostConfig,
 	})
 
 	if err == nil {
@@ -236,6 +368,75 @@ func (buildJob *BuildJob) getContainer(dataDir string, env []string) (*docker.Co
 	return container, err
 }
 
+// Follow the logs of a container and append them to
+// the log archive. The returned chan gets closed after
+// the container exited and all logs were written.
+// Logs older than since (unix time) are skipped
+func (buildJob *BuildJob) archiveLogs(containerID string, since int64) <-chan struct{} {
+	done := make(chan struct{})
+
+	if len(buildJob.LogFile) == 0 {
+		close(done)
+		return done
+	}
+
+	go func() {
+		defer close(done)
+
+		archive, err := OpenLogArchive(buildJob.LogFile)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		err = buildJob.Logs(docker.LogsOptions{
+			Container:    containerID,
+			Stderr:       true,
+			Stdout:       true,
+			Follow:       true,
+			Since:        since,
+			OutputStream: archive,
+			ErrorStream:  archive,
+		})
+		if err != nil {
+			log.Warn(err)
+		}
+
+		if err = archive.Close(); err != nil {
+			log.Error(err)
+		}
+	}()
+
+	return done
+}
+
+// Return true if the container was killed for running out of memory
+func (buildJob *BuildJob) wasOOMKilled(containerID string) bool {
+	container, err := buildJob.InspectContainer(containerID)
+	if err != nil {
+		log.Warn(err)
+		return false
+	}
+
+	return container.State.OOMKilled
+}
+
+// Remove a container unless build containers should be kept
+func (buildJob *BuildJob) removeContainer(containerID string) {
+	if buildJob.Config.Server.KeepBuildContainer {
+		return
+	}
+
+	err := buildJob.RemoveContainer(docker.RemoveContainerOptions{
+		ID:    containerID,
+		Force: true,
+	})
+
+	if err != nil {
+		log.Warn(err)
+	}
+}
+
 func (buildJob *BuildJob) hasImage(image string) (bool, error) {
 	// Get all images
 	images, err := buildJob.ListImages(docker.ListImagesOptions{All: false})
@@ -325,6 +526,25 @@ func (buildJob *BuildJob) cancel() {
 	buildJob.State = libremotebuild.JobCancelled
 }
 
+// FollowLogs writes the logs of the running container
+// to w until the container exits or ctx is done
+func (buildJob *BuildJob) FollowLogs(ctx context.Context, w io.Writer) error {
+	containerID := buildJob.ContainerID
+	if buildJob.State != libremotebuild.JobRunning || len(containerID) == 0 {
+		return ErrJobNotRunning
+	}
+
+	return buildJob.Logs(docker.LogsOptions{
+		Context:      ctx,
+		Container:    containerID,
+		Stderr:       true,
+		Stdout:       true,
+		Follow:       true,
+		OutputStream: w,
+		ErrorStream:  w,
+	})
+}
+
 // GetLogs of Buildjob
 func (buildJob *BuildJob) GetLogs(since int64, w io.Writer, tail string) error {
 	// Check build is running
@@ -350,3 +570,17 @@ func (buildJob *BuildJob) GetLogs(since int64, w io.Writer, tail string) error {
 	// Get container logs
 	return buildJob.Logs(logOptions)
 }
+
+// Reattach to the container of a previous server
+// run instead of creating a new one on the next run
+func (buildJob *BuildJob) Reattach(containerID string) {
+	buildJob.ContainerID = containerID
+	buildJob.reattach = true
+}
+
+// Record a new stage of the job
+func (buildJob *BuildJob) enterStage(stage JobStage, reason string) {
+	if buildJob.onStage != nil {
+		buildJob.onStage(stage, reason)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/JojiiOfficial/configService"
 	"github.com/JojiiOfficial/gaw"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
 )
 
 //Config config for the server
@@ -36,9 +37,18 @@ type configServer struct {
 	KeepBuildContainer        bool
 	KeepBuildFiles            bool
 	DeleteUnusedSessionsAfter time.Duration `default:"10m"`
+	SessionIdleTimeout        time.Duration `default:"24h"`  // Sessions expire if unused for. 0 to disable
+	SessionLifetime           time.Duration `default:"720h"` // Max age of sessions. 0 to disable
+	PasswordCost              int           `default:"12"`   // bcrypt cost of password hashes
 	Ccache                    ccacheConfig
 	CustomMirror              string
 	LocalStoragePath          string
+	BuildLogs                 buildLogConfig
+}
+
+type buildLogConfig struct {
+	Dir       string        // Directory to archive build logs in. Empty to disable
+	Retention time.Duration // Delete archived logs after. 0 to keep them forever
 }
 
 type ccacheConfig struct {
@@ -47,7 +57,33 @@ type ccacheConfig struct {
 }
 
 type jobconfig struct {
-	Images map[string]string
+	Images          map[string]string
+	MaxParallel     int           `default:"1"` // Max count of jobs running at the same time
+	BuildTimeout    time.Duration // Max duration of a build. 0 to disable
+	MaxBuildTimeout time.Duration `default:"24h"` // Max timeout users can request for a job. 0 for no limit
+	Retry           retryConfig
+	Limits          map[string]ResourceLimits // Container limits by build type
+	MaxLimits       ResourceLimits            // Max limits users can request for a job
+	OnRestart       RestartAction             `default:"stop"` // What to do with build containers of a previous server run
+	DrainTimeout    time.Duration             `default:"1h"`   // Max time to wait for running jobs when draining
+	Dedup           bool                      // Skip builds of AUR packages which are stored in LocalStoragePath already
+}
+
+// RestartAction action for build containers
+// left from a previous server run
+type RestartAction string
+
+// Restart actions
+const (
+	RestartReattach RestartAction = "reattach" // Wait for the container and continue the job
+	RestartStop     RestartAction = "stop"     // Stop and remove the container and rebuild the job
+)
+
+type retryConfig struct {
+	MaxAttempts      int           `default:"1"`
+	Backoff          time.Duration `default:"30s"`
+	Phases           []JobPhase
+	MaxAttemptsLimit int `default:"5"` // Max attempts users can request for a job. 0 for no limit
 }
 
 type configDBstruct struct {
@@ -125,9 +161,27 @@ func InitConfig(confFile string, createMode bool) (*Config, bool) {
 					Images: map[string]string{
 						libremotebuild.JobAUR.String(): "jojii/buildaur:v2.8",
 					},
+					MaxParallel:     1,
+					BuildTimeout:    3 * time.Hour,
+					MaxBuildTimeout: 24 * time.Hour,
+					OnRestart:       RestartStop,
+					DrainTimeout:    time.Hour,
+					Retry: retryConfig{
+						MaxAttempts:      1,
+						Backoff:          30 * time.Second,
+						Phases:           []JobPhase{PhasePull, PhaseUpload},
+						MaxAttemptsLimit: 5,
+					},
 				},
 				DeleteUnusedSessionsAfter: 10 * time.Minute,
+				SessionIdleTimeout:        24 * time.Hour,
+				SessionLifetime:           30 * 24 * time.Hour,
+				PasswordCost:              12,
 				LocalStoragePath:          "/var/remotebuild/output",
+				BuildLogs: buildLogConfig{
+					Dir:       "/var/remotebuild/logs",
+					Retention: 30 * 24 * time.Hour,
+				},
 			},
 			Webserver: webserverConf{
 				HTTP: configHTTPstruct{
@@ -199,6 +253,55 @@ func (config *Config) Check() bool {
 		}
 	}
 
+	// Check password hash cost
+	if config.Server.PasswordCost == 0 {
+		config.Server.PasswordCost = bcrypt.DefaultCost
+	}
+	if config.Server.PasswordCost < bcrypt.MinCost || config.Server.PasswordCost > bcrypt.MaxCost {
+		log.Errorf("Invalid passwordcost %d. Use a value between %d and %d", config.Server.PasswordCost, bcrypt.MinCost, bcrypt.MaxCost)
+		return false
+	}
+
+	// Check retry policy
+	if config.Server.Jobs.Retry.MaxAttemptsLimit < 0 {
+		log.Error("Invalid retry config: maxattemptslimit can't be negative")
+		return false
+	}
+	if err := config.GetRetryPolicy().Check(config.Server.Jobs.Retry.MaxAttemptsLimit); err != nil {
+		log.Errorf("Invalid retry config: %s", err)
+		return false
+	}
+
+	// Check build timeout
+	if config.Server.Jobs.BuildTimeout < 0 || config.Server.Jobs.MaxBuildTimeout < 0 {
+		log.Error("Invalid build timeout: timeouts can't be negative")
+		return false
+	}
+	if config.Server.Jobs.BuildTimeout > 0 && config.CheckBuildTimeout(config.Server.Jobs.BuildTimeout) != nil {
+		log.Error("Invalid build timeout: buildtimeout exceeds maxbuildtimeout")
+		return false
+	}
+
+	// Check container limits
+	for buildType, limits := range config.Server.Jobs.Limits {
+		if err := limits.Check(ResourceLimits{}); err != nil {
+			log.Errorf("Invalid limits for %s: %s", buildType, err)
+			return false
+		}
+	}
+	if err := config.Server.Jobs.MaxLimits.Check(ResourceLimits{}); err != nil {
+		log.Errorf("Invalid max limits: %s", err)
+		return false
+	}
+
+	// Check restart action
+	switch config.Server.Jobs.OnRestart {
+	case RestartReattach, RestartStop:
+	default:
+		log.Errorf("Invalid jobs.onrestart '%s'. Use '%s' or '%s'", config.Server.Jobs.OnRestart, RestartReattach, RestartStop)
+		return false
+	}
+
 	// Print Warning if ccache is not set up properly
 	if !config.IsCcacheDirValid() {
 		log.Warn("Ccache directory is not valid")
@@ -230,6 +333,14 @@ func (config *Config) Check() bool {
 
 	}
 
+	// Create build log dir if not exists
+	if len(config.Server.BuildLogs.Dir) > 0 && !DirExists(config.Server.BuildLogs.Dir) {
+		if err := os.MkdirAll(config.Server.BuildLogs.Dir, 0700); err != nil {
+			log.Errorf("Cannot create build log dir: %s", err)
+			return false
+		}
+	}
+
 	return true
 }
 
@@ -268,3 +379,41 @@ func (config Config) IsCcacheDirValid() bool {
 
 	return len(config.Server.Ccache.Dir) > 0 && gaw.FileExists(config.Server.Ccache.Dir)
 }
+
+// CheckBuildTimeout returns an error if a timeout requested
+// for a job is not positive or exceeds MaxBuildTimeout
+func (config Config) CheckBuildTimeout(timeout time.Duration) error {
+	if timeout <= 0 {
+		return ErrInvalidTimeout
+	}
+
+	if config.Server.Jobs.MaxBuildTimeout > 0 && timeout > config.Server.Jobs.MaxBuildTimeout {
+		return ErrTimeoutExceeded
+	}
+
+	return nil
+}
+
+// GetRetryPolicy returns the default retry policy for jobs
+func (config Config) GetRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: config.Server.Jobs.Retry.MaxAttempts,
+		Backoff:     config.Server.Jobs.Retry.Backoff,
+		Phases:      config.Server.Jobs.Retry.Phases,
+	}
+}
+
+// GetLimits returns the container limits for buildType
+func (config Config) GetLimits(buildType libremotebuild.JobType) ResourceLimits {
+	return config.Server.Jobs.Limits[buildType.String()]
+}
+
+// GetLogArchivePath returns the path of the archived
+// logs of a job or an empty string if disabled
+func (config Config) GetLogArchivePath(jobID uint) string {
+	if len(config.Server.BuildLogs.Dir) == 0 {
+		return ""
+	}
+
+	return GetLogArchivePath(config.Server.BuildLogs.Dir, jobID)
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// AURRPCURL url of the AUR rpc interface
+var AURRPCURL = "https://aur.archlinux.org/rpc/"
+
+// ErrPackageNotFound if a package doesn't exist in the AUR
+var ErrPackageNotFound = errors.New("Package not found")
+
+var aurClient = &http.Client{
+	Timeout: 15 * time.Second,
+}
+
+// ResolveAURVersion returns the current version of an AUR package
+func ResolveAURVersion(pkg string) (string, error) {
+	query := url.Values{}
+	query.Set("v", "5")
+	query.Set("type", "info")
+	query.Set("arg[]", pkg)
+
+	resp, err := aurClient.Get(AURRPCURL + "?" + query.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("AUR returned %s", resp.Status)
+	}
+
+	var result struct {
+		Results []struct {
+			Name    string
+			Version string
+		}
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	for _, res := range result.Results {
+		if res.Name == pkg {
+			return res.Version, nil
+		}
+	}
+
+	return "", ErrPackageNotFound
+}
+
+// FindStoredBuild returns the artifacts of a build stored
+// by a LocalStorage upload. Returns nil if not found
+func FindStoredBuild(storagePath, name, version string) (*ResInfo, error) {
+	if len(storagePath) == 0 {
+		return nil, nil
+	}
+
+	entries, err := ioutil.ReadDir(storagePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	// Builds are stored as <buildID>-<name>-<version>
+	suffix := fmt.Sprintf("-%s-%s", name, version)
+
+	var latest os.FileInfo
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), suffix) || !isNumber(strings.TrimSuffix(entry.Name(), suffix)) {
+			continue
+		}
+
+		if latest == nil || entry.ModTime().After(latest.ModTime()) {
+			latest = entry
+		}
+	}
+
+	if latest == nil {
+		return nil, nil
+	}
+
+	path := filepath.Join(storagePath, latest.Name())
+	resInfo := &ResInfo{
+		Name:    name,
+		Version: version,
+	}
+
+	// Single files are stored without a directory
+	if !latest.IsDir() {
+		resInfo.Files = []string{path}
+		return resInfo, nil
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if !file.IsDir() {
+			resInfo.Files = append(resInfo.Files, filepath.Join(path, file.Name()))
+		}
+	}
+
+	if len(resInfo.Files) == 0 {
+		return nil, nil
+	}
+
+	return resInfo, nil
+}
+
+// Return true if s only contains digits
+func isNumber(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
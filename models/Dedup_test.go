@@ -0,0 +1,72 @@
+package models
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveAURVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("arg[]") != "yay" {
+			w.Write([]byte(`{"resultcount":0,"results":[]}`))
+			return
+		}
+
+		w.Write([]byte(`{"resultcount":1,"results":[{"Name":"yay","Version":"10.1.0-1"}]}`))
+	}))
+	defer server.Close()
+
+	oldURL := AURRPCURL
+	AURRPCURL = server.URL + "/"
+	defer func() {
+		AURRPCURL = oldURL
+	}()
+
+	version, err := ResolveAURVersion("yay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "10.1.0-1" {
+		t.Errorf("Expected version 10.1.0-1. Got %s", version)
+	}
+
+	if _, err = ResolveAURVersion("unknown"); err != ErrPackageNotFound {
+		t.Errorf("Expected ErrPackageNotFound. Got %v", err)
+	}
+}
+
+func TestFindStoredBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Single file build and a similar named package
+	if err = ioutil.WriteFile(filepath.Join(dir, "3-yay-10.1.0-1"), []byte("pkg"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "4-foo-yay-10.1.0-1"), []byte("pkg"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	resInfo, err := FindStoredBuild(dir, "yay", "10.1.0-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resInfo == nil || len(resInfo.Files) != 1 || resInfo.Files[0] != filepath.Join(dir, "3-yay-10.1.0-1") {
+		t.Fatalf("Unexpected result %+v", resInfo)
+	}
+
+	if resInfo, err = FindStoredBuild(dir, "yay", "10.2.0-1"); err != nil || resInfo != nil {
+		t.Errorf("Expected no build for another version. Got %+v, %v", resInfo, err)
+	}
+
+	if resInfo, err = FindStoredBuild(dir, "foo", "10.1.0-1"); err != nil || resInfo != nil {
+		t.Errorf("Expected no build for another package. Got %+v, %v", resInfo, err)
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrInvalidDuration if a duration is neither a number nor a duration string
+var ErrInvalidDuration = errors.New("Invalid duration. Use seconds or a duration like \"30m\"")
+
+// Duration a duration in requests. Accepts seconds
+// or a duration string like "1h30m" in json
+type Duration time.Duration
+
+// UnmarshalJSON parses seconds or a duration string
+func (duration *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*duration = Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return ErrInvalidDuration
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return ErrInvalidDuration
+	}
+
+	*duration = Duration(d)
+	return nil
+}
+
+// MarshalJSON encodes the duration as duration string
+func (duration Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(duration).String())
+}
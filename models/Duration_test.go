@@ -0,0 +1,33 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDurationUnmarshal(t *testing.T) {
+	cases := map[string]time.Duration{
+		`90`:      90 * time.Second,
+		`1.5`:     1500 * time.Millisecond,
+		`"30m"`:   30 * time.Minute,
+		`"1h30m"`: 90 * time.Minute,
+	}
+
+	for input, expected := range cases {
+		var d Duration
+		if err := json.Unmarshal([]byte(input), &d); err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+
+		if time.Duration(d) != expected {
+			t.Errorf("%s: expected %s. Got %s", input, expected, time.Duration(d))
+		}
+	}
+
+	var d Duration
+	if err := json.Unmarshal([]byte(`"soon"`), &d); err == nil {
+		t.Error("Expected an error for an invalid duration")
+	}
+}
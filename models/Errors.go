@@ -5,14 +5,53 @@ import "errors"
 // ErrorUserAlreadyExists error if user exists
 var ErrorUserAlreadyExists = errors.New("user already exists")
 
+// ErrInvalidCredentials if username or password is wrong
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrUserNotFound if a user doesn't exist
+var ErrUserNotFound = errors.New("user not found")
+
 // ErrorJobCancelled error if user exists
 var ErrorJobCancelled = errors.New("job cancelled")
 
 // ErrorNonZeroExit error if user exists
 var ErrorNonZeroExit = errors.New("Non zero exit code from container")
 
+// ErrorJobTimedOut error if a build exceeded its timeout
+var ErrorJobTimedOut = errors.New("job timed out")
+
 // ErrJobNotRunning if job is not running
 var ErrJobNotRunning = errors.New("Job not running")
 
 // ErrNoLogsFound if no logs were found
 var ErrNoLogsFound = errors.New("No logs found")
+
+// ErrDependencyNotFound if a job depends on a non existing job
+var ErrDependencyNotFound = errors.New("Dependency not found")
+
+// ErrInvalidRetryPolicy if a retry policy is invalid
+var ErrInvalidRetryPolicy = errors.New("Invalid retry policy")
+
+// ErrRetryAttemptsExceeded if a retry policy allows more attempts than configured
+var ErrRetryAttemptsExceeded = errors.New("Retry attempts exceed the allowed maximum")
+
+// ErrInvalidTimeout if a build timeout is not positive
+var ErrInvalidTimeout = errors.New("Invalid timeout")
+
+// ErrTimeoutExceeded if a build timeout exceeds the allowed maximum
+var ErrTimeoutExceeded = errors.New("Timeout exceeds the allowed maximum")
+
+// ErrInvalidLimits if resource limits are invalid
+var ErrInvalidLimits = errors.New("Invalid resource limits")
+
+// ErrLimitsExceeded if resource limits exceed the allowed maximum
+var ErrLimitsExceeded = errors.New("Resource limits exceed the allowed maximum")
+
+// ErrorOutOfMemory error if a build got killed for running out of memory
+var ErrorOutOfMemory = errors.New("Build killed: out of memory")
+
+// ErrNoArgsStored if the args of a job are not available anymore
+var ErrNoArgsStored = errors.New("Args of the job are not stored")
+
+// ErrMissingSecretArgs if secret args have to be passed again
+var ErrMissingSecretArgs = errors.New("Secret args missing")
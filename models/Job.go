@@ -2,10 +2,12 @@ package models
 
 import (
 	"bytes"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
@@ -26,23 +28,48 @@ type Job struct {
 	UploadJobID uint       `sql:"index"`
 	UploadJob   *UploadJob `gorm:"association_autoupdate:false;association_autocreate:false"`
 
+	// User who created the job
+	UserID uint `sql:"index"`
+
+	// Batch the job was submitted in. 0 if none
+	BatchID uint `sql:"index"`
+
+	// Build even if an equal build exists already
+	Force bool
+
 	DataDir  string // Shared dir containing build files
 	Result   string // Message of an exited job
 	LastLogs string // Latest logs
-	Argdata  string `grom:"type:jsonb"`
+	Argdata  string `grom:"type:jsonb"` // Args without secrets
 	Info     string
 	Duration int64
 
+	// Secret args are only kept until the job is done
+	SecretArgdata string
+	SecretArgs    string // Comma separated names of the secret args
+
+	// Retry policy
+	Attempts     int // Count of attempts to run the job
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	RetryPhases  string // Comma separated list of phases to retry
+
 	Args           map[string]string `gorm:"-"` // Envars for Dockerimage
+	DependsOn      []uint            `gorm:"-"` // Jobs which have to be done before this job
+	FailedAttempts []JobAttempt      `gorm:"-"` // Only set if loaded with LoadAttempts
+	Transitions    []JobTransition   `gorm:"-"` // Only set if loaded with LoadTransitions
 	*gorm.DB       `gorm:"-"`
 	Cancelled      bool          `gorm:"-"`
 	LastSince      int64         `gorm:"-"`
 	stopLogUpdater chan struct{} `gorm:"-"`
+	cancelChan     chan struct{} `gorm:"-"`
+	events         *eventHub     `gorm:"-"`
+	stage          JobStage      `gorm:"-"` // Last recorded stage
 	config         *Config       `gorm:"-"`
 }
 
 // NewJob create a new job
-func NewJob(db *gorm.DB, config *Config, image string, buildJob BuildJob, uploadJob UploadJob, args map[string]string, useCcache bool) (*Job, error) {
+func NewJob(db *gorm.DB, config *Config, image string, buildJob BuildJob, uploadJob UploadJob, args map[string]string, useCcache bool, retry RetryPolicy) (*Job, error) {
 	// Create temporary path for storing build data
 	path := filepath.Join(os.TempDir(), "remotebuild_"+gaw.RandString(30))
 	err := os.MkdirAll(path, 0700)
@@ -50,15 +77,27 @@ func NewJob(db *gorm.DB, config *Config, image string, buildJob BuildJob, upload
 		return nil, err
 	}
 
+	// Remove the build dir if the job can't be created
+	defer func() {
+		if err != nil {
+			if rmErr := os.RemoveAll(path); rmErr != nil {
+				log.Warn(rmErr)
+			}
+		}
+	}()
+
 	job := &Job{
 		DataDir:        path,
 		Args:           args,
 		DB:             db,
 		stopLogUpdater: make(chan struct{}, 1),
+		cancelChan:     make(chan struct{}, 1),
+		events:         newEventHub(),
 		config:         config,
 	}
 
 	job.putArgs()
+	job.setRetryPolicy(retry)
 
 	// Create BuildJob
 	bJob, err := NewBuildJob(db, config, buildJob, image, useCcache)
@@ -80,42 +119,190 @@ func NewJob(db *gorm.DB, config *Config, image string, buildJob BuildJob, upload
 		return nil, err
 	}
 
+	job.BuildJob = bJob
+	job.UploadJob = upjob
+	job.SetStage(StageQueued, "")
+
 	return job, nil
 }
 
+// LoadJob loads a job with its sub jobs, failed attempts
+// and timeline. Returns nil if the job doesn't exist
+func LoadJob(db *gorm.DB, jobID uint) (*Job, error) {
+	var job Job
+
+	err := db.Model(&Job{}).
+		Preload("BuildJob").
+		Preload("UploadJob").
+		Where("id=?", jobID).
+		First(&job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	// Load failed attempts
+	if err = job.LoadAttempts(db); err != nil {
+		return nil, err
+	}
+
+	// Load timeline
+	if err = job.LoadTransitions(db); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
 // Init Job
 func (job *Job) Init(db *gorm.DB, config *Config) error {
-	// Init channel
+	// Init channels
 	if job.stopLogUpdater == nil {
 		job.stopLogUpdater = make(chan struct{}, 1)
 	}
+	if job.cancelChan == nil {
+		job.cancelChan = make(chan struct{}, 1)
+	}
+	if job.events == nil {
+		job.events = newEventHub()
+	}
 
 	job.config = config
 	job.DB = db
 
+	if job.BuildJob != nil {
+		job.BuildJob.Config = config
+	}
+
+	// Load args
 	if job.Args == nil {
-		// TODO load argdata
+		if err := job.loadArgs(); err != nil {
+			return err
+		}
+	}
+
+	// Load dependencies
+	if job.DependsOn == nil {
+		var err error
+		if job.DependsOn, err = GetDependencies(db, job.ID); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// Tranlate Args to Argdata
-func (job *Job) putArgs() error {
-	b, err := json.Marshal(job.Args)
+// Tranlate Args to Argdata. Secrets
+// are stored separately
+func (job *Job) putArgs() (err error) {
+	job.Argdata, job.SecretArgdata, job.SecretArgs, err = encodeArgs(job.Args)
+	return
+}
+
+// Translate Argdata and SecretArgdata back to Args
+func (job *Job) loadArgs() error {
+	args, err := job.GetArgs()
 	if err != nil {
 		return err
 	}
 
-	job.Argdata = string(b)
+	secrets, err := parseArgs(job.SecretArgdata)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range secrets {
+		args[k] = v
+	}
 
+	job.Args = args
 	return nil
 }
 
+// GetArgs returns the args of the job without secrets
+func (job *Job) GetArgs() (map[string]string, error) {
+	return parseArgs(job.Argdata)
+}
+
+// GetSecretArgNames returns the names of the secret
+// args the job was created with
+func (job *Job) GetSecretArgNames() []string {
+	if len(job.SecretArgs) == 0 {
+		return nil
+	}
+
+	return strings.Split(job.SecretArgs, ",")
+}
+
+// Set the retry policy of the job
+func (job *Job) setRetryPolicy(policy RetryPolicy) {
+	phases := make([]string, len(policy.Phases))
+	for i := range policy.Phases {
+		phases[i] = string(policy.Phases[i])
+	}
+
+	job.MaxAttempts = policy.MaxAttempts
+	job.RetryBackoff = policy.Backoff
+	job.RetryPhases = strings.Join(phases, ",")
+}
+
+// GetRetryPolicy returns the retry policy of the job
+func (job *Job) GetRetryPolicy() RetryPolicy {
+	// Jobs created without a policy use the default one
+	if job.MaxAttempts == 0 {
+		return job.config.GetRetryPolicy()
+	}
+
+	policy := RetryPolicy{
+		MaxAttempts: job.MaxAttempts,
+		Backoff:     job.RetryBackoff,
+	}
+
+	for _, phase := range strings.Split(job.RetryPhases, ",") {
+		if len(phase) > 0 {
+			policy.Phases = append(policy.Phases, JobPhase(phase))
+		}
+	}
+
+	return policy
+}
+
+// LoadAttempts loads the failed attempts of the job
+func (job *Job) LoadAttempts(db *gorm.DB) (err error) {
+	job.FailedAttempts, err = GetAttempts(db, job.ID)
+	return
+}
+
+// LoadTransitions loads the stage changes of the job
+func (job *Job) LoadTransitions(db *gorm.DB) (err error) {
+	job.Transitions, err = GetTransitions(db, job.ID)
+	return
+}
+
+// SetStage records a new stage of the job. Only
+// the first final stage gets recorded
+func (job *Job) SetStage(stage JobStage, reason string) {
+	if stage.IsFinal() && job.stage.IsFinal() {
+		return
+	}
+	job.stage = stage
+
+	if err := SaveTransition(job.DB, job.ID, stage, reason); err != nil {
+		log.Error(err)
+	}
+}
+
 // Cancel Job
 func (job *Job) Cancel() {
 	// Cancle actions
-	job.stopLogUpdater <- struct{}{}
+	job.stopLogs()
+	select {
+	case job.cancelChan <- struct{}{}:
+	default:
+	}
 	job.BuildJob.cancel()
 	job.UploadJob.cancel()
 
@@ -124,6 +311,23 @@ func (job *Job) Cancel() {
 	job.Result = "Cancelled"
 
 	job.cleanup()
+	job.SetStage(StageCancelled, "")
+	job.events.publish(EventResult, job.Result)
+}
+
+// Subscribe to events of the job. The returned func unsubscribes
+func (job *Job) Subscribe() (<-chan JobEvent, func()) {
+	return job.events.subscribe()
+}
+
+// Fail a job which can't be run
+func (job *Job) Fail(reason string) {
+	job.SetState(libremotebuild.JobFailed)
+	job.Result = reason
+
+	job.cleanup()
+	job.SetStage(StageFailed, reason)
+	job.events.publish(EventResult, job.Result)
 }
 
 // SetState set the state of a job
@@ -171,8 +375,8 @@ func (job *Job) cleanup() {
 		}
 	}
 
-	// Clean Argdata
-	job.Argdata = ""
+	// Clean secrets. The other args are kept to rerun the job
+	job.SecretArgdata = ""
 
 	// Save changes and
 	job.Save()
@@ -208,28 +412,75 @@ func (job *Job) Run() error {
 
 	// Cleanup data at the end
 	defer func() {
-		job.stopLogUpdater <- struct{}{}
+		job.stopLogs()
 		job.cleanup()
+		job.SetStage(StageByState(job.GetState()), job.Result)
+		job.events.publish(EventResult, job.Result)
 	}()
 
 	go job.runLogUpdater()
 
 	// New argParser
 	argParser := NewArgParser(job.Args, job.BuildJob.Type)
+	policy := job.GetRetryPolicy()
+	job.Attempts++
+
+	// Archive full build logs
+	job.BuildJob.LogFile = job.config.GetLogArchivePath(job.ID)
+	job.BuildJob.events = job.events
+	job.BuildJob.JobID = job.ID
+	job.BuildJob.onStage = job.SetStage
+
+	// Reuse the artifacts of an equal build
+	buildResult, upToDate := job.findExistingBuild()
+
+	// Resolving the version takes a while. Don't
+	// start the build if the job got cancelled meanwhile
+	if job.Cancelled {
+		return ErrorJobCancelled
+	}
+
+	if upToDate && job.UploadJob.Type == libremotebuild.LocalStorage {
+		// Artifacts are stored already
+		job.SetState(libremotebuild.JobDone)
+		job.Result = "Up to date"
+		return nil
+	}
 
 	// Run Build
-	buildResult, duration := job.BuildJob.Run(job.DataDir, argParser)
+	for !upToDate {
+		var duration *time.Duration
+		buildResult, duration = job.BuildJob.Run(job.DataDir, argParser)
+		if buildResult.Error == nil {
+			job.Duration = int64(duration.Seconds())
+			break
+		}
 
-	if buildResult.Error != nil {
-		if buildResult.Error != ErrorJobCancelled {
-			job.SetState(libremotebuild.JobFailed)
-			log.Info("Build Failed: ", buildResult.Error.Error())
+		if buildResult.Error == ErrorJobCancelled {
+			return buildResult.Error
 		}
 
-		return buildResult.Error
-	}
+		// Timed out builds don't get retried
+		if buildResult.Error == ErrorJobTimedOut {
+			job.recordAttempt(PhaseBuild, buildResult.Error)
+			job.SetState(JobTimedOut)
+			job.Duration = int64(duration.Seconds())
+			job.Result = fmt.Sprintf("Build timed out after %s", job.BuildJob.Timeout)
+			log.Infof("Job %d: %s", job.ID, job.Result)
+			return buildResult.Error
+		}
 
-	job.Duration = int64(duration.Seconds())
+		if !job.retry(policy, buildResult.GetPhase(), buildResult.Error) {
+			if job.Cancelled {
+				return ErrorJobCancelled
+			}
+
+			job.SetState(libremotebuild.JobFailed)
+			job.Result = "Build failed: " + buildResult.Error.Error()
+			log.Info(job.Result)
+			return buildResult.Error
+		}
+	}
 
 	err := job.Save()
 	if err != nil {
@@ -241,21 +492,119 @@ func (job *Job) Run() error {
 	}
 
 	// Run upload
-	uploadResult := job.UploadJob.Run(*buildResult, argParser, job.config)
-	if uploadResult != nil && uploadResult.Error != nil {
-		if uploadResult.Error != ErrorJobCancelled {
-			job.SetState(libremotebuild.JobFailed)
-			log.Info("Upload Failed: ", uploadResult.Error.Error())
+	job.SetStage(StageUploading, "")
+	job.events.publish(EventPhase, string(PhaseUpload))
+	for {
+		uploadResult := job.UploadJob.Run(*buildResult, argParser, job.config)
+		if uploadResult == nil || uploadResult.Error == nil {
+			break
+		}
+
+		if uploadResult.Error == ErrorJobCancelled {
+			return uploadResult.Error
 		}
 
-		return uploadResult.Error
+		if !job.retry(policy, PhaseUpload, uploadResult.Error) {
+			if job.Cancelled {
+				return ErrorJobCancelled
+			}
+
+			job.SetState(libremotebuild.JobFailed)
+			job.Result = "Upload failed: " + uploadResult.Error.Error()
+			log.Info(job.Result)
+			return uploadResult.Error
+		}
 	}
 
 	log.Infof("Job %d done", job.ID)
 	job.Result = "Success"
+	if upToDate {
+		job.Result = "Up to date"
+	}
+
 	return nil
 }
 
+// findExistingBuild returns the stored artifacts of an equal
+// build if dedup is enabled and the job isn't forced
+func (job *Job) findExistingBuild() (*BuildResult, bool) {
+	if !job.config.Server.Jobs.Dedup || job.Force || job.BuildJob.Type != libremotebuild.JobAUR {
+		return nil, false
+	}
+
+	pkg := job.Args[libremotebuild.AURPackage]
+
+	version, err := ResolveAURVersion(pkg)
+	if err != nil {
+		log.Warnf("Job %d: Can't resolve version of %s: %s", job.ID, pkg, err)
+		return nil, false
+	}
+
+	resInfo, err := FindStoredBuild(job.config.Server.LocalStoragePath, pkg, version)
+	if err != nil {
+		log.Warn(err)
+		return nil, false
+	}
+
+	if resInfo == nil {
+		return nil, false
+	}
+	resInfo.JobID = job.BuildJob.ID
+
+	log.Infof("Job %d: %s %s is up to date", job.ID, pkg, version)
+	job.BuildJob.State = libremotebuild.JobDone
+	job.SetStage(StageCollecting, fmt.Sprintf("%s %s is up to date", pkg, version))
+
+	return &BuildResult{resinfo: resInfo}, true
+}
+
+// retry records a failed attempt and waits until the job can be
+// retried. Returns false if the job shouldn't be retried anymore
+func (job *Job) retry(policy RetryPolicy, phase JobPhase, err error) bool {
+	job.recordAttempt(phase, err)
+
+	if job.Cancelled || job.Attempts >= policy.MaxAttempts || !policy.Retries(phase) {
+		return false
+	}
+
+	// Double backoff for each retry
+	backoff := policy.Backoff
+	for i := 1; i < job.Attempts && i < 10; i++ {
+		backoff *= 2
+	}
+
+	log.Infof("Job %d: %s failed in attempt %d/%d. Retrying in %s: %s", job.ID, phase, job.Attempts, policy.MaxAttempts, backoff, err)
+	job.SetStage(StageQueued, fmt.Sprintf("Retry after %s failed: %s", phase, err))
+
+	// Wait for backoff or cancel
+	select {
+	case <-time.After(backoff):
+	case <-job.cancelChan:
+		return false
+	}
+
+	job.Attempts++
+	if err := job.Save(); err != nil {
+		log.Error(err)
+	}
+
+	return true
+}
+
+// recordAttempt saves a failed attempt of the job
+func (job *Job) recordAttempt(phase JobPhase, err error) {
+	attempt := JobAttempt{
+		JobID:   job.ID,
+		Attempt: job.Attempts,
+		Phase:   phase,
+		Error:   err.Error(),
+	}
+
+	if dbErr := job.DB.Create(&attempt).Error; dbErr != nil {
+		log.Error(dbErr)
+	}
+}
+
 // GetLogs for job
 func (job *Job) GetLogs(requestTime time.Time, since int64, w io.Writer, checkAmbigious bool) error {
 	if checkAmbigious {
@@ -283,6 +632,15 @@ func (job *Job) GetLogs(requestTime time.Time, since int64, w io.Writer, checkAm
 	return ErrNoLogsFound
 }
 
+// Stop the log updater without blocking if
+// it was already stopped
+func (job *Job) stopLogs() {
+	select {
+	case job.stopLogUpdater <- struct{}{}:
+	default:
+	}
+}
+
 func (job *Job) runLogUpdater() {
 	wasJobRunning := false
 
@@ -332,13 +690,35 @@ func (job *Job) runLogUpdater() {
 }
 
 // ToJobInfo return JobInfo by job
-func (job Job) ToJobInfo() libremotebuild.JobInfo {
-	return libremotebuild.JobInfo{
-		ID:         job.ID,
-		Info:       job.GetInfo(),
-		BuildType:  job.BuildJob.Type,
-		Status:     job.GetState(),
-		UploadType: job.UploadJob.Type,
-		Duration:   time.Duration(job.Duration) * time.Second,
+func (job Job) ToJobInfo() JobInfo {
+	info := JobInfo{
+		JobInfo: libremotebuild.JobInfo{
+			ID:         job.ID,
+			Info:       job.GetInfo(),
+			BuildType:  job.BuildJob.Type,
+			Status:     job.GetState(),
+			UploadType: job.UploadJob.Type,
+			Duration:   time.Duration(job.Duration) * time.Second,
+		},
+		StateName: StateName(job.GetState()),
+		Attempts:  job.Attempts,
+		BatchID:   job.BatchID,
+		UserID:    job.UserID,
 	}
+
+	if job.Transitions != nil {
+		timeline := NewTimeline(job.Transitions, time.Now())
+		info.Timeline = &timeline
+	}
+
+	for _, attempt := range job.FailedAttempts {
+		info.FailedAttempts = append(info.FailedAttempts, AttemptInfo{
+			Attempt: attempt.Attempt,
+			Phase:   attempt.Phase,
+			Error:   attempt.Error,
+			Time:    attempt.CreatedAt,
+		})
+	}
+
+	return info
 }
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
+)
+
+// Parts of arg names which mark an arg as secret
+var secretArgParts = []string{"TOKEN", "PASS", "SECRET", "KEY"}
+
+// IsSecretArg returns true if the arg holds a secret. Secrets
+// are only kept in the database until the job is done
+func IsSecretArg(name string) bool {
+	if name == libremotebuild.DMToken {
+		return true
+	}
+
+	upper := strings.ToUpper(name)
+	for _, part := range secretArgParts {
+		if strings.Contains(upper, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SplitArgs splits args into public args and secrets
+func SplitArgs(args map[string]string) (public map[string]string, secrets map[string]string) {
+	public = make(map[string]string)
+	secrets = make(map[string]string)
+
+	for k, v := range args {
+		if IsSecretArg(k) {
+			secrets[k] = v
+		} else {
+			public[k] = v
+		}
+	}
+
+	return
+}
+
+// Encode args as json. Secrets are encoded separately
+// and their sorted names are returned comma separated
+func encodeArgs(args map[string]string) (argdata, secretArgdata, secretNames string, err error) {
+	public, secrets := SplitArgs(args)
+
+	b, err := json.Marshal(public)
+	if err != nil {
+		return
+	}
+	argdata = string(b)
+
+	b, err = json.Marshal(secrets)
+	if err != nil {
+		return
+	}
+	secretArgdata = string(b)
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	secretNames = strings.Join(names, ",")
+
+	return
+}
+
+// MissingArgs returns the sorted names which are not set in args
+func MissingArgs(args map[string]string, names []string) []string {
+	var missing []string
+	for _, name := range names {
+		if _, ok := args[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+
+	sort.Strings(missing)
+	return missing
+}
+
+// Unmarshal json encoded args
+func parseArgs(data string) (map[string]string, error) {
+	args := make(map[string]string)
+	if len(data) == 0 {
+		return args, nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &args); err != nil {
+		return nil, err
+	}
+
+	return args, nil
+}
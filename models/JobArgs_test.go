@@ -0,0 +1,42 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
+)
+
+func TestSplitArgs(t *testing.T) {
+	public, secrets := SplitArgs(map[string]string{
+		libremotebuild.AURPackage:  "yay",
+		libremotebuild.DMUser:      "user",
+		libremotebuild.DMToken:     "token",
+		libremotebuild.DMNamespace: "ns",
+		"GPG_PASSPHRASE":           "pass",
+	})
+
+	wantPublic := map[string]string{
+		libremotebuild.AURPackage:  "yay",
+		libremotebuild.DMUser:      "user",
+		libremotebuild.DMNamespace: "ns",
+	}
+	wantSecrets := map[string]string{
+		libremotebuild.DMToken: "token",
+		"GPG_PASSPHRASE":       "pass",
+	}
+
+	if !reflect.DeepEqual(public, wantPublic) {
+		t.Errorf("public = %v, want %v", public, wantPublic)
+	}
+	if !reflect.DeepEqual(secrets, wantSecrets) {
+		t.Errorf("secrets = %v, want %v", secrets, wantSecrets)
+	}
+}
+
+func TestMissingArgs(t *testing.T) {
+	missing := MissingArgs(map[string]string{"A": "1"}, []string{"C", "A", "B"})
+	if !reflect.DeepEqual(missing, []string{"B", "C"}) {
+		t.Errorf("missing = %v", missing)
+	}
+}
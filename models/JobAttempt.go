@@ -0,0 +1,120 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JobPhase a phase of a job which can be retried
+type JobPhase string
+
+// Retryable phases
+const (
+	PhasePull   JobPhase = "pull"   // Pulling the docker image
+	PhaseBuild  JobPhase = "build"  // Building the package
+	PhaseUpload JobPhase = "upload" // Uploading the built files
+)
+
+// IsValidPhase return true if phase is a known phase
+func IsValidPhase(phase JobPhase) bool {
+	switch phase {
+	case PhasePull, PhaseBuild, PhaseUpload:
+		return true
+	}
+
+	return false
+}
+
+// RetryPolicy describes how often and in
+// which phases a failed job gets retried
+type RetryPolicy struct {
+	MaxAttempts int           `json:"maxAttempts"` // Including the first attempt
+	Backoff     time.Duration `json:"backoff"`     // Wait before the first retry. Doubles with each further retry
+	Phases      []JobPhase    `json:"phases"`      // Phases which get retried
+}
+
+// retryPolicyJSON json representation of a RetryPolicy
+type retryPolicyJSON struct {
+	MaxAttempts int        `json:"maxAttempts"`
+	Backoff     Duration   `json:"backoff"`
+	Phases      []JobPhase `json:"phases"`
+}
+
+// UnmarshalJSON reads the backoff as seconds or duration string
+func (policy *RetryPolicy) UnmarshalJSON(data []byte) error {
+	var p retryPolicyJSON
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+
+	*policy = RetryPolicy{
+		MaxAttempts: p.MaxAttempts,
+		Backoff:     time.Duration(p.Backoff),
+		Phases:      p.Phases,
+	}
+	return nil
+}
+
+// MarshalJSON writes the backoff as duration string
+func (policy RetryPolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(retryPolicyJSON{
+		MaxAttempts: policy.MaxAttempts,
+		Backoff:     Duration(policy.Backoff),
+		Phases:      policy.Phases,
+	})
+}
+
+// Check return an error if the policy is invalid or allows
+// more than maxAttempts attempts. 0 for no maximum
+func (policy RetryPolicy) Check(maxAttempts int) error {
+	if policy.MaxAttempts < 1 || policy.Backoff < 0 {
+		return ErrInvalidRetryPolicy
+	}
+
+	if maxAttempts > 0 && policy.MaxAttempts > maxAttempts {
+		return ErrRetryAttemptsExceeded
+	}
+
+	for _, phase := range policy.Phases {
+		if !IsValidPhase(phase) {
+			return ErrInvalidRetryPolicy
+		}
+	}
+
+	return nil
+}
+
+// Retries return true if failures in phase get retried
+func (policy RetryPolicy) Retries(phase JobPhase) bool {
+	for i := range policy.Phases {
+		if policy.Phases[i] == phase {
+			return true
+		}
+	}
+
+	return false
+}
+
+// JobAttempt a failed attempt of running a job
+type JobAttempt struct {
+	gorm.Model
+
+	JobID   uint `sql:"index"`
+	Attempt int
+	Phase   JobPhase
+	Error   string
+}
+
+// GetAttempts returns all failed attempts of a job
+func GetAttempts(db *gorm.DB, jobID uint) ([]JobAttempt, error) {
+	var attempts []JobAttempt
+
+	err := db.Model(&JobAttempt{}).
+		Where("job_id=?", jobID).
+		Order("attempt").
+		Find(&attempts).Error
+
+	return attempts, err
+}
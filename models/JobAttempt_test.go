@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyRoundtrip(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     10 * time.Second,
+		Phases:      []JobPhase{PhasePull, PhaseUpload},
+	}
+
+	job := Job{}
+	job.setRetryPolicy(policy)
+	got := job.GetRetryPolicy()
+
+	if got.MaxAttempts != 3 || got.Backoff != 10*time.Second || len(got.Phases) != 2 {
+		t.Fatalf("Expected another policy. Got: %v", got)
+	}
+
+	if !got.Retries(PhaseUpload) || got.Retries(PhaseBuild) {
+		t.Errorf("Expected only pull and upload to be retried. Got: %v", got.Phases)
+	}
+}
+
+func TestRetryPolicyCheck(t *testing.T) {
+	if (RetryPolicy{MaxAttempts: 0}).Check(0) == nil {
+		t.Error("Expected an error for zero attempts")
+	}
+
+	if (RetryPolicy{MaxAttempts: 2, Phases: []JobPhase{"compile"}}).Check(0) == nil {
+		t.Error("Expected an error for an unknown phase")
+	}
+
+	if (RetryPolicy{MaxAttempts: 2, Backoff: -time.Second}).Check(0) == nil {
+		t.Error("Expected an error for a negative backoff")
+	}
+
+	if err := (RetryPolicy{MaxAttempts: 6}).Check(5); err != ErrRetryAttemptsExceeded {
+		t.Errorf("Expected ErrRetryAttemptsExceeded. Got %v", err)
+	}
+
+	if err := (RetryPolicy{MaxAttempts: 100}).Check(0); err != nil {
+		t.Errorf("Expected no limit. Got %v", err)
+	}
+}
+
+func TestRetryPolicyJSON(t *testing.T) {
+	var policy RetryPolicy
+	if err := json.Unmarshal([]byte(`{"maxAttempts":3,"backoff":"2m","phases":["build"]}`), &policy); err != nil {
+		t.Fatal(err)
+	}
+	if policy.MaxAttempts != 3 || policy.Backoff != 2*time.Minute || !policy.Retries(PhaseBuild) {
+		t.Fatalf("Expected another policy. Got: %v", policy)
+	}
+
+	data, err := json.Marshal(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded RetryPolicy
+	if err = json.Unmarshal(data, &decoded); err != nil || decoded.Backoff != policy.Backoff {
+		t.Errorf("Expected backoff %s after roundtrip. Got %s %v", policy.Backoff, decoded.Backoff, err)
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// JobDependency a job which has to be
+// done before another job can run
+type JobDependency struct {
+	gorm.Model
+
+	JobID        uint `sql:"index"` // The dependent job
+	DependencyID uint // The job JobID depends on
+}
+
+// SaveDependencies saves the dependencies of a job
+func SaveDependencies(db *gorm.DB, jobID uint, dependencies []uint) error {
+	if len(dependencies) == 0 {
+		return nil
+	}
+
+	deps := make([]JobDependency, len(dependencies))
+	for i := range dependencies {
+		deps[i] = JobDependency{
+			JobID:        jobID,
+			DependencyID: dependencies[i],
+		}
+	}
+
+	return db.Create(&deps).Error
+}
+
+// GetDependencies returns the IDs of all jobs jobID depends on
+func GetDependencies(db *gorm.DB, jobID uint) ([]uint, error) {
+	var ids []uint
+
+	err := db.Model(&JobDependency{}).
+		Where("job_id=?", jobID).
+		Pluck("dependency_id", &ids).Error
+
+	return ids, err
+}
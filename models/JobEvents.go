@@ -0,0 +1,72 @@
+package models
+
+import (
+	"sync"
+)
+
+// Job event types
+const (
+	EventPhase  = "phase"  // The job entered a new phase. Data is the JobPhase
+	EventResult = "result" // The job exited. Data is the result of the job
+)
+
+// JobEvent an event of a job
+type JobEvent struct {
+	Type string
+	Data string
+}
+
+// eventHub broadcasts events of a job to all subscribers
+type eventHub struct {
+	mx          sync.Mutex
+	subscribers map[chan JobEvent]struct{}
+	result      *JobEvent // The result once the job exited
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subscribers: make(map[chan JobEvent]struct{}),
+	}
+}
+
+// Subscribe to events. The returned func unsubscribes.
+// Subscribers of an exited job receive its result
+func (hub *eventHub) subscribe() (<-chan JobEvent, func()) {
+	ch := make(chan JobEvent, 10)
+
+	hub.mx.Lock()
+	if hub.result != nil {
+		ch <- *hub.result
+	}
+	hub.subscribers[ch] = struct{}{}
+	hub.mx.Unlock()
+
+	return ch, func() {
+		hub.mx.Lock()
+		delete(hub.subscribers, ch)
+		hub.mx.Unlock()
+	}
+}
+
+// Publish an event. Subscribers which
+// aren't ready to receive miss the event
+func (hub *eventHub) publish(eventType, data string) {
+	if hub == nil {
+		return
+	}
+
+	hub.mx.Lock()
+	defer hub.mx.Unlock()
+
+	event := JobEvent{Type: eventType, Data: data}
+	if eventType == EventResult {
+		hub.result = &event
+	}
+
+	for ch := range hub.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
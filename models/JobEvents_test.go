@@ -0,0 +1,21 @@
+package models
+
+import "testing"
+
+func TestSubscribeAfterResult(t *testing.T) {
+	hub := newEventHub()
+	hub.publish(EventPhase, string(PhaseBuild))
+	hub.publish(EventResult, "done")
+
+	events, unsubscribe := hub.subscribe()
+	defer unsubscribe()
+
+	select {
+	case event := <-events:
+		if event.Type != EventResult || event.Data != "done" {
+			t.Fatalf("expected the result, got %v", event)
+		}
+	default:
+		t.Fatal("expected the result of the exited job")
+	}
+}
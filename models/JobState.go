@@ -0,0 +1,18 @@
+package models
+
+import (
+	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
+)
+
+// JobTimedOut state of a job which exceeded its build
+// timeout. Extends the states of libremotebuild
+const JobTimedOut libremotebuild.JobState = 6
+
+// StateName returns the name of a job state
+func StateName(state libremotebuild.JobState) string {
+	if state == JobTimedOut {
+		return "Timed out"
+	}
+
+	return state.String()
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"time"
+
+	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
+	"gorm.io/gorm"
+)
+
+// JobStage a stage in the lifecycle of a job
+type JobStage string
+
+// Job stages
+const (
+	StageQueued     JobStage = "queued"
+	StagePulling    JobStage = "pulling image"
+	StageBuilding   JobStage = "building"
+	StageCollecting JobStage = "collecting artifacts"
+	StageUploading  JobStage = "uploading"
+	StageDone       JobStage = "done"
+	StageFailed     JobStage = "failed"
+	StageCancelled  JobStage = "cancelled"
+	StageTimedOut   JobStage = "timed out"
+)
+
+// IsFinal returns true if a job can't leave the stage
+func (stage JobStage) IsFinal() bool {
+	switch stage {
+	case StageDone, StageFailed, StageCancelled, StageTimedOut:
+		return true
+	}
+
+	return false
+}
+
+// StageByState returns the final stage for a job state
+func StageByState(state libremotebuild.JobState) JobStage {
+	switch state {
+	case libremotebuild.JobDone:
+		return StageDone
+	case libremotebuild.JobCancelled:
+		return StageCancelled
+	case JobTimedOut:
+		return StageTimedOut
+	}
+
+	return StageFailed
+}
+
+// JobTransition a change of the stage of a job
+type JobTransition struct {
+	ID     uint `gorm:"primarykey"`
+	JobID  uint `sql:"index"`
+	Stage  JobStage
+	Reason string
+	Time   time.Time
+}
+
+// SaveTransition saves a stage change of a job
+func SaveTransition(db *gorm.DB, jobID uint, stage JobStage, reason string) error {
+	return db.Create(&JobTransition{
+		JobID:  jobID,
+		Stage:  stage,
+		Reason: reason,
+		Time:   time.Now(),
+	}).Error
+}
+
+// GetTransitions returns all stage changes of a job
+func GetTransitions(db *gorm.DB, jobID uint) ([]JobTransition, error) {
+	var transitions []JobTransition
+
+	err := db.Model(&JobTransition{}).
+		Where("job_id=?", jobID).
+		Order("time, id").
+		Find(&transitions).Error
+
+	return transitions, err
+}
+
+// NewTimeline creates a timeline of transitions. The last
+// stage of an unfinished job lasts until now
+func NewTimeline(transitions []JobTransition, now time.Time) Timeline {
+	timeline := Timeline{
+		Durations: make(map[JobStage]time.Duration),
+	}
+
+	for i, transition := range transitions {
+		entry := TimelineEntry{
+			Stage:  transition.Stage,
+			Reason: transition.Reason,
+			Start:  transition.Time,
+		}
+
+		if i+1 < len(transitions) {
+			entry.Duration = transitions[i+1].Time.Sub(transition.Time)
+		} else if !transition.Stage.IsFinal() {
+			entry.Duration = now.Sub(transition.Time)
+		}
+
+		timeline.Entries = append(timeline.Entries, entry)
+		if !transition.Stage.IsFinal() {
+			timeline.Durations[transition.Stage] += entry.Duration
+		}
+	}
+
+	return timeline
+}
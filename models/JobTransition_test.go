@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeline(t *testing.T) {
+	start := time.Date(2020, 11, 20, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	timeline := NewTimeline([]JobTransition{
+		{Stage: StageQueued, Time: at(0)},
+		{Stage: StagePulling, Time: at(5)},
+		{Stage: StageBuilding, Time: at(7)},
+		{Stage: StageQueued, Time: at(20), Reason: "Retry"},
+		{Stage: StagePulling, Time: at(21)},
+		{Stage: StageBuilding, Time: at(22)},
+		{Stage: StageUploading, Time: at(42)},
+		{Stage: StageDone, Time: at(45)},
+	}, at(100))
+
+	expected := map[JobStage]time.Duration{
+		StageQueued:    6 * time.Minute,
+		StagePulling:   3 * time.Minute,
+		StageBuilding:  33 * time.Minute,
+		StageUploading: 3 * time.Minute,
+	}
+
+	if len(timeline.Durations) != len(expected) {
+		t.Errorf("Expected %d stages. Got %v", len(expected), timeline.Durations)
+	}
+	for stage, duration := range expected {
+		if timeline.Durations[stage] != duration {
+			t.Errorf("Expected %s for %s. Got %s", duration, stage, timeline.Durations[stage])
+		}
+	}
+
+	if last := timeline.Entries[len(timeline.Entries)-1]; last.Duration != 0 {
+		t.Errorf("Final stage should have no duration. Got %s", last.Duration)
+	}
+}
+
+func TestTimelineUnfinished(t *testing.T) {
+	start := time.Date(2020, 11, 20, 12, 0, 0, 0, time.UTC)
+
+	timeline := NewTimeline([]JobTransition{
+		{Stage: StageQueued, Time: start},
+		{Stage: StageBuilding, Time: start.Add(time.Minute)},
+	}, start.Add(10*time.Minute))
+
+	if d := timeline.Durations[StageBuilding]; d != 9*time.Minute {
+		t.Errorf("Expected running stage to last until now. Got %s", d)
+	}
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Maximum amount of data returned by a single ReadLogArchive call
+const (
+	MaxLogArchiveBytes = 1024 * 1024
+	MaxLogArchiveLines = 10000
+)
+
+// LogArchive a gzip compressed logfile of a job
+type LogArchive struct {
+	file *os.File
+	gz   *gzip.Writer
+}
+
+// LogArchivePage a part of an archived log
+type LogArchivePage struct {
+	Content []byte
+	Next    int64 // Offset of the next page
+	EOF     bool  // True if there is no more content
+}
+
+// GetLogArchivePath returns the path of the log archive for a job
+func GetLogArchivePath(dir string, jobID uint) string {
+	return filepath.Join(dir, fmt.Sprintf("%d.log.gz", jobID))
+}
+
+// OpenLogArchive opens a log archive for appending. Each
+// opening appends a new gzip member to the file
+func OpenLogArchive(path string) (*LogArchive, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LogArchive{
+		file: f,
+		gz:   gzip.NewWriter(f),
+	}, nil
+}
+
+// Write to the archive
+func (archive *LogArchive) Write(p []byte) (int, error) {
+	return archive.gz.Write(p)
+}
+
+// Close the archive
+func (archive *LogArchive) Close() error {
+	if err := archive.gz.Close(); err != nil {
+		archive.file.Close()
+		return err
+	}
+
+	return archive.file.Close()
+}
+
+// ReadLogArchive reads limit bytes, or lines if byLines is
+// set, starting at offset from the archive at path
+func ReadLogArchive(path string, offset, limit int64, byLines bool) (*LogArchivePage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		// Nothing was flushed yet
+		if err == io.EOF {
+			return &LogArchivePage{Next: offset, EOF: true}, nil
+		}
+
+		return nil, err
+	}
+	defer gz.Close()
+
+	if byLines {
+		return readLines(gz, offset, limit)
+	}
+
+	return readBytes(gz, offset, limit)
+}
+
+func readBytes(r io.Reader, offset, limit int64) (*LogArchivePage, error) {
+	if limit <= 0 || limit > MaxLogArchiveBytes {
+		limit = MaxLogArchiveBytes
+	}
+
+	// Skip to offset
+	skipped, err := io.CopyN(ioutil.Discard, r, offset)
+	if err != nil {
+		if isEOF(err) {
+			return &LogArchivePage{Next: skipped, EOF: true}, nil
+		}
+
+		return nil, err
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(r, limit))
+	if err != nil && !isEOF(err) {
+		return nil, err
+	}
+
+	return &LogArchivePage{
+		Content: content,
+		Next:    offset + int64(len(content)),
+		EOF:     int64(len(content)) < limit,
+	}, nil
+}
+
+func readLines(r io.Reader, offset, limit int64) (*LogArchivePage, error) {
+	if limit <= 0 || limit > MaxLogArchiveLines {
+		limit = MaxLogArchiveLines
+	}
+
+	reader := bufio.NewReader(r)
+	page := &LogArchivePage{}
+
+	var line int64
+	for ; line < offset+limit; line++ {
+		content, err := reader.ReadBytes('\n')
+		if line >= offset {
+			page.Content = append(page.Content, content...)
+		}
+
+		if err != nil {
+			if !isEOF(err) {
+				return nil, err
+			}
+
+			// Count incomplete last line
+			if len(content) > 0 {
+				line++
+			}
+
+			page.EOF = true
+			break
+		}
+	}
+
+	page.Next = line
+	return page, nil
+}
+
+// Logs of running jobs end with an incomplete gzip stream
+func isEOF(err error) bool {
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func writeTestArchive(t *testing.T, path string, content string) {
+	archive, err := OpenLogArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive.Write([]byte(content))
+	if err = archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogArchivePaging(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remotebuild_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := GetLogArchivePath(dir, 1)
+
+	// Two attempts append two gzip members
+	writeTestArchive(t, path, "line1\nline2\n")
+	writeTestArchive(t, path, "line3\nline4\n")
+
+	page, err := ReadLogArchive(path, 1, 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(page.Content) != "line2\nline3\n" || page.Next != 3 || page.EOF {
+		t.Errorf("Expected lines 2-3. Got: %q next: %d eof: %t", page.Content, page.Next, page.EOF)
+	}
+
+	page, err = ReadLogArchive(path, 18, 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(page.Content) != "line4\n" || page.Next != 24 || !page.EOF {
+		t.Errorf("Expected last line. Got: %q next: %d eof: %t", page.Content, page.Next, page.EOF)
+	}
+}
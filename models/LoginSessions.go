@@ -1,43 +1,152 @@
 package models
 
 import (
+	"errors"
+	"time"
+
 	"github.com/JojiiOfficial/gaw"
 	log "github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
+// ErrSessionNotFound if a session doesn't exist
+var ErrSessionNotFound = errors.New("session not found")
+
 // LoginSession session for loggedin user
 type LoginSession struct {
 	gorm.Model
 	User      *User `gorm:"association_autoupdate:false;association_autocreate:false"`
 	UserID    uint
-	Token     string
+	Token     string `gorm:"-"`    // Plain token. Only set on creation
+	TokenHash string `sql:"index"` // Only the hash of the token is stored
 	Requests  int64
 	MachineID string
+	LastUsed  time.Time
 }
 
 // SessionTokenLength length of session token
 const SessionTokenLength = 64
 
-// GetUserFromSession return user from session
-func GetUserFromSession(db *gorm.DB, token string) (*User, error) {
+// GetSession return the session of the token. Returns
+// nil if the session doesn't exist or is expired
+func GetSession(db *gorm.DB, config *Config, token string) (*LoginSession, error) {
 	var session LoginSession
-	err := db.Model(&LoginSession{}).Where(&LoginSession{
-		Token: token,
-	}).Preload("User").Find(&session).Error
-
+	err := db.Model(&LoginSession{}).
+		Where("token_hash = ?", HashToken(token)).
+		Preload("User").
+		First(&session).Error
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
 		return nil, err
 	}
 
+	if session.IsExpired(config) {
+		if err = db.Unscoped().Delete(&session).Error; err != nil {
+			log.Error(err)
+		}
+
+		return nil, nil
+	}
+
 	// Increase request counter
 	session.Requests++
-	err = db.Save(&session).Error
+	session.LastUsed = time.Now()
+	err = db.Model(&session).UpdateColumns(map[string]interface{}{
+		"requests":  gorm.Expr("requests + 1"),
+		"last_used": session.LastUsed,
+	}).Error
 	if err != nil {
 		log.Error(err)
 	}
 
-	return session.User, nil
+	return &session, nil
+}
+
+// IsExpired returns true if the session wasn't used within the idle
+// timeout or exceeded its lifetime
+func (session *LoginSession) IsExpired(config *Config) bool {
+	idle := config.Server.SessionIdleTimeout
+	if idle > 0 && time.Since(session.lastActivity()) > idle {
+		return true
+	}
+
+	lifetime := config.Server.SessionLifetime
+	return lifetime > 0 && time.Since(session.CreatedAt) > lifetime
+}
+
+// Time the session was last used
+func (session *LoginSession) lastActivity() time.Time {
+	if session.LastUsed.After(session.CreatedAt) {
+		return session.LastUsed
+	}
+
+	return session.CreatedAt
+}
+
+// GetSessions returns all sessions of a user
+func GetSessions(db *gorm.DB, userID uint) ([]LoginSession, error) {
+	var sessions []LoginSession
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession deletes a session of the user
+func RevokeSession(db *gorm.DB, userID, sessionID uint) error {
+	res := db.Unscoped().Where("user_id = ? AND id = ?", userID, sessionID).Delete(&LoginSession{})
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RevokeSessions deletes all sessions of the user
+func RevokeSessions(db *gorm.DB, userID uint) (int64, error) {
+	res := db.Unscoped().Where("user_id = ?", userID).Delete(&LoginSession{})
+	return res.RowsAffected, res.Error
+}
+
+// DeleteExpiredSessions deletes all expired sessions
+func DeleteExpiredSessions(db *gorm.DB, config *Config) (int64, error) {
+	var deleted int64
+
+	if idle := config.Server.SessionIdleTimeout; idle > 0 {
+		res := db.Unscoped().
+			Where("last_used < ? AND created_at < ?", time.Now().Add(-idle), time.Now().Add(-idle)).
+			Delete(&LoginSession{})
+		if res.Error != nil {
+			return deleted, res.Error
+		}
+
+		deleted += res.RowsAffected
+	}
+
+	if lifetime := config.Server.SessionLifetime; lifetime > 0 {
+		res := db.Unscoped().Where("created_at < ?", time.Now().Add(-lifetime)).Delete(&LoginSession{})
+		if res.Error != nil {
+			return deleted, res.Error
+		}
+
+		deleted += res.RowsAffected
+	}
+
+	return deleted, nil
+}
+
+// DeleteLegacySessions deletes sessions stored with a plain token
+func DeleteLegacySessions(db *gorm.DB) (int64, error) {
+	res := db.Unscoped().Where("token_hash IS NULL OR token_hash = ''").Delete(&LoginSession{})
+	return res.RowsAffected, res.Error
 }
 
 // NewSession create new login session
@@ -69,8 +178,10 @@ func NewSession(user *User, machineID string) *LoginSession {
 	//Generate session
 	return &LoginSession{
 		Token:     token,
+		TokenHash: HashToken(token),
 		UserID:    user.ID,
 		User:      user,
 		MachineID: machineID,
+		LastUsed:  time.Now(),
 	}
 }
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionIsExpired(t *testing.T) {
+	config := &Config{}
+	config.Server.SessionIdleTimeout = time.Hour
+	config.Server.SessionLifetime = 24 * time.Hour
+
+	now := time.Now()
+	tests := []struct {
+		created  time.Time
+		lastUsed time.Time
+		expected bool
+	}{
+		{now, now, false},
+		{now.Add(-2 * time.Hour), now.Add(-time.Minute), false},
+		{now.Add(-2 * time.Hour), now.Add(-2 * time.Hour), true},
+		{now.Add(-2 * time.Hour), time.Time{}, true},
+		{now.Add(-25 * time.Hour), now, true},
+	}
+
+	for i, test := range tests {
+		session := LoginSession{LastUsed: test.lastUsed}
+		session.CreatedAt = test.created
+
+		if got := session.IsExpired(config); got != test.expected {
+			t.Errorf("Test %d: expected %t. Got %t", i, test.expected, got)
+		}
+	}
+
+	// Disabled timeouts
+	session := LoginSession{}
+	session.CreatedAt = now.Add(-100 * 24 * time.Hour)
+	if session.IsExpired(&Config{}) {
+		t.Error("Sessions must not expire if timeouts are disabled")
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/JojiiOfficial/gaw"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashPassword hashes a password using bcrypt
+func HashPassword(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// isBcryptHash returns true if hash is a bcrypt hash.
+// Hashes of older versions are SHA512(username+password)
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2")
+}
+
+// CheckPassword returns true if the password matches the stored hash of
+// the user. needsRehash is true if the hash is outdated and should be
+// replaced by a hash of the configured cost
+func (user *User) CheckPassword(password string, cost int) (ok, needsRehash bool) {
+	if !isBcryptHash(user.Password) {
+		legacy := gaw.SHA512(user.GetUsername() + password)
+		return subtle.ConstantTimeCompare([]byte(legacy), []byte(user.Password)) == 1, true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return false, false
+	}
+
+	hashCost, err := bcrypt.Cost([]byte(user.Password))
+	return true, err != nil || hashCost != cost
+}
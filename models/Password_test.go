@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/JojiiOfficial/gaw"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("secret", bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := User{Username: "Bob", Password: hash}
+
+	if ok, rehash := user.CheckPassword("secret", bcrypt.MinCost); !ok || rehash {
+		t.Errorf("Expected valid password without rehash. Got %t %t", ok, rehash)
+	}
+
+	if ok, _ := user.CheckPassword("wrong", bcrypt.MinCost); ok {
+		t.Error("Expected wrong password to fail")
+	}
+
+	// Cost got changed
+	if ok, rehash := user.CheckPassword("secret", bcrypt.MinCost+1); !ok || !rehash {
+		t.Errorf("Expected rehash on cost change. Got %t %t", ok, rehash)
+	}
+
+	// Hash of older versions
+	user.Password = gaw.SHA512("bob" + "secret")
+	if ok, rehash := user.CheckPassword("secret", bcrypt.MinCost); !ok || !rehash {
+		t.Errorf("Expected legacy hash to match and require a rehash. Got %t %t", ok, rehash)
+	}
+
+	if ok, _ := user.CheckPassword("wrong", bcrypt.MinCost); ok {
+		t.Error("Expected wrong password to fail for legacy hash")
+	}
+}
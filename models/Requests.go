@@ -0,0 +1,157 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
+)
+
+// JobPriority priority of a job in the queue
+type JobPriority int8
+
+// Job priorities
+const (
+	PriorityLow    JobPriority = -1
+	PriorityNormal JobPriority = 0
+	PriorityUrgent JobPriority = 1
+)
+
+func (priority JobPriority) String() string {
+	switch priority {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityUrgent:
+		return "urgent"
+	}
+
+	return "<invalid>"
+}
+
+// ParseJobPriority parse a priority from string. Returns
+// false if the priority is not valid
+func ParseJobPriority(s string) (JobPriority, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "low":
+		return PriorityLow, true
+	case "", "normal":
+		return PriorityNormal, true
+	case "urgent":
+		return PriorityUrgent, true
+	}
+
+	return PriorityNormal, false
+}
+
+// AddJobRequest request for creating a new job
+type AddJobRequest struct {
+	libremotebuild.AddJobRequest
+	Priority  string          `json:"priority,omitempty"`
+	DependsOn []uint          `json:"dependsOn,omitempty"` // IDs of jobs which have to succeed before
+	Retry     *RetryPolicy    `json:"retry,omitempty"`     // Overrides the default retry policy
+	Timeout   Duration        `json:"timeout,omitempty"`   // Overrides the default build timeout
+	Limits    *ResourceLimits `json:"limits,omitempty"`    // Overrides the default container limits
+	Force     bool            `json:"force,omitempty"`     // Build even if the result exists already
+}
+
+// JobMoveAction where to move a job in the queue
+type JobMoveAction string
+
+// Move actions
+const (
+	MoveFront  JobMoveAction = "front"
+	MoveBack   JobMoveAction = "back"
+	MoveBefore JobMoveAction = "before"
+	MoveAfter  JobMoveAction = "after"
+)
+
+// ReorderJobRequest request for moving a job in the queue
+type ReorderJobRequest struct {
+	JobID    uint          `json:"id"`
+	Action   JobMoveAction `json:"action"`
+	TargetID uint          `json:"target,omitempty"` // Required for MoveBefore and MoveAfter
+}
+
+// AddScheduleRequest request for creating a new schedule
+type AddScheduleRequest struct {
+	libremotebuild.AddJobRequest
+	Priority string `json:"priority,omitempty"`
+	Name     string `json:"name"`
+	Cron     string `json:"cron"`
+}
+
+// ScheduleRequest request for a single schedule
+type ScheduleRequest struct {
+	ID uint `json:"id"`
+}
+
+// ArchivedLogsRequest request for a page of archived build logs
+type ArchivedLogsRequest struct {
+	JobID   uint  `json:"id"`
+	ByLines bool  `json:"lines"`  // Offset and limit are lines instead of bytes
+	Offset  int64 `json:"offset"` // First byte/line to return
+	Limit   int64 `json:"limit"`  // Max bytes/lines to return
+}
+
+// RerunJobRequest request for creating a new job
+// with the settings of an existing job
+type RerunJobRequest struct {
+	JobID      uint                       `json:"id"`
+	Args       map[string]string          `json:"args,omitempty"`       // Overrides args. Secrets have to be passed again
+	UploadType *libremotebuild.UploadType `json:"uploadtype,omitempty"` // Overrides the upload type
+	Priority   string                     `json:"priority,omitempty"`
+	Timeout    Duration                   `json:"timeout,omitempty"` // Overrides the build timeout
+	Force      bool                       `json:"force,omitempty"`   // Build even if the result exists already
+}
+
+// AddBatchRequest request for creating multiple jobs at once
+type AddBatchRequest struct {
+	Jobs []AddJobRequest `json:"jobs"`
+}
+
+// BatchRequest request for a batch
+type BatchRequest struct {
+	BatchID uint `json:"id"`
+}
+
+// ListBatchesRequest request for listing batches
+type ListBatchesRequest struct {
+	Limit int `json:"limit"`
+}
+
+// SetRoleRequest request for changing the role of a user
+type SetRoleRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// CreateTokenRequest request for creating an API token
+type CreateTokenRequest struct {
+	Name    string        `json:"name"`
+	Scopes  []string      `json:"scopes"`
+	Expires time.Duration `json:"expires,omitempty"` // Token never expires if 0
+}
+
+// TokenRequest request for a single API token
+type TokenRequest struct {
+	Name string `json:"name"`
+}
+
+// RevokeSessionRequest request for revoking sessions
+type RevokeSessionRequest struct {
+	ID  uint `json:"id,omitempty"`
+	All bool `json:"all,omitempty"` // Revoke all sessions except the current one
+}
+
+// UserRequest request for a single user
+type UserRequest struct {
+	Username string `json:"username"`
+}
+
+// ChangePasswordRequest request for changing the own password
+type ChangePasswordRequest struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
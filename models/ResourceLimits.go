@@ -0,0 +1,192 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+
+	units "github.com/docker/go-units"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// ResourceLimits limits for a build container
+type ResourceLimits struct {
+	CPUShares  int64    `json:"cpuShares,omitempty"`  // Relative CPU weight
+	CPUQuota   int64    `json:"cpuQuota,omitempty"`   // CPU time in microseconds per CPUPeriod
+	CPUPeriod  int64    `json:"cpuPeriod,omitempty"`  // Period for CPUQuota in microseconds
+	Memory     string   `json:"memory,omitempty"`     // Memory limit, eg. "4g"
+	MemorySwap string   `json:"memorySwap,omitempty"` // Memory + swap limit. "-1" for unlimited swap
+	PidsLimit  int64    `json:"pidsLimit,omitempty"`
+	Ulimits    []Ulimit `json:"ulimits,omitempty"`
+}
+
+// Ulimit a ulimit for a build container
+type Ulimit struct {
+	Name string `json:"name"`
+	Soft int64  `json:"soft"`
+	Hard int64  `json:"hard"`
+}
+
+// IsEmpty return true if no limit is set
+func (limits ResourceLimits) IsEmpty() bool {
+	return limits.CPUShares == 0 && limits.CPUQuota == 0 && limits.CPUPeriod == 0 &&
+		len(limits.Memory) == 0 && len(limits.MemorySwap) == 0 &&
+		limits.PidsLimit == 0 && len(limits.Ulimits) == 0
+}
+
+// Merge returns limits with all values set in override replaced
+func (limits ResourceLimits) Merge(override ResourceLimits) ResourceLimits {
+	if override.CPUShares != 0 {
+		limits.CPUShares = override.CPUShares
+	}
+	if override.CPUQuota != 0 {
+		limits.CPUQuota = override.CPUQuota
+	}
+	if override.CPUPeriod != 0 {
+		limits.CPUPeriod = override.CPUPeriod
+	}
+	if len(override.Memory) > 0 {
+		limits.Memory = override.Memory
+	}
+	if len(override.MemorySwap) > 0 {
+		limits.MemorySwap = override.MemorySwap
+	}
+	if override.PidsLimit != 0 {
+		limits.PidsLimit = override.PidsLimit
+	}
+
+	// Override ulimits by name
+	if len(override.Ulimits) > 0 {
+		ulimits := make([]Ulimit, 0, len(limits.Ulimits)+len(override.Ulimits))
+		for _, ulimit := range limits.Ulimits {
+			if _, overridden := findUlimit(override.Ulimits, ulimit.Name); !overridden {
+				ulimits = append(ulimits, ulimit)
+			}
+		}
+
+		limits.Ulimits = append(ulimits, override.Ulimits...)
+	}
+
+	return limits
+}
+
+// Check returns an error if a limit is invalid
+// or exceeds a limit set in max
+func (limits ResourceLimits) Check(max ResourceLimits) error {
+	memory, err := parseMemory(limits.Memory)
+	if err != nil {
+		return err
+	}
+	swap, err := parseMemory(limits.MemorySwap)
+	if err != nil {
+		return err
+	}
+	maxMemory, err := parseMemory(max.Memory)
+	if err != nil {
+		return err
+	}
+	maxSwap, err := parseMemory(max.MemorySwap)
+	if err != nil {
+		return err
+	}
+
+	if limits.CPUShares < 0 || limits.CPUQuota < 0 || limits.CPUPeriod < 0 || limits.PidsLimit < 0 {
+		return ErrInvalidLimits
+	}
+
+	if exceeds(limits.CPUShares, max.CPUShares) ||
+		exceeds(limits.CPUQuota, max.CPUQuota) ||
+		exceeds(memory, maxMemory) ||
+		exceeds(swap, maxSwap) ||
+		exceeds(limits.PidsLimit, max.PidsLimit) {
+		return ErrLimitsExceeded
+	}
+
+	for _, ulimit := range limits.Ulimits {
+		if len(ulimit.Name) == 0 || ulimit.Soft > ulimit.Hard {
+			return ErrInvalidLimits
+		}
+
+		if maxUlimit, has := findUlimit(max.Ulimits, ulimit.Name); has && ulimit.Hard > maxUlimit.Hard {
+			return ErrLimitsExceeded
+		}
+	}
+
+	return nil
+}
+
+// Apply the limits to a containers HostConfig
+func (limits ResourceLimits) Apply(hostConfig *docker.HostConfig) error {
+	memory, err := parseMemory(limits.Memory)
+	if err != nil {
+		return err
+	}
+	swap, err := parseMemory(limits.MemorySwap)
+	if err != nil {
+		return err
+	}
+
+	hostConfig.CPUShares = limits.CPUShares
+	hostConfig.CPUQuota = limits.CPUQuota
+	hostConfig.CPUPeriod = limits.CPUPeriod
+	hostConfig.Memory = memory
+	hostConfig.MemorySwap = swap
+
+	if limits.PidsLimit > 0 {
+		pidsLimit := limits.PidsLimit
+		hostConfig.PidsLimit = &pidsLimit
+	}
+
+	for _, ulimit := range limits.Ulimits {
+		hostConfig.Ulimits = append(hostConfig.Ulimits, docker.ULimit{
+			Name: ulimit.Name,
+			Soft: ulimit.Soft,
+			Hard: ulimit.Hard,
+		})
+	}
+
+	return nil
+}
+
+// String return limits as json
+func (limits ResourceLimits) String() string {
+	b, _ := json.Marshal(limits)
+	return string(b)
+}
+
+// Parse memory strings like "4g". Returns -1 for "-1" and 0 if empty
+func parseMemory(s string) (int64, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "-1":
+		return -1, nil
+	}
+
+	n, err := units.RAMInBytes(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidLimits, err)
+	}
+
+	return n, nil
+}
+
+// Return true if value exceeds max. Values <= 0 for
+// max mean there is no maximum, 0 for value means unset
+func exceeds(value, max int64) bool {
+	if max <= 0 || value == 0 {
+		return false
+	}
+
+	// Unlimited exceeds every maximum
+	return value > max || value < 0
+}
+
+func findUlimit(ulimits []Ulimit, name string) (Ulimit, bool) {
+	for _, ulimit := range ulimits {
+		if ulimit.Name == name {
+			return ulimit, true
+		}
+	}
+
+	return Ulimit{}, false
+}
@@ -0,0 +1,44 @@
+package models
+
+import "testing"
+
+func TestResourceLimitsMerge(t *testing.T) {
+	defaults := ResourceLimits{
+		Memory:    "4g",
+		PidsLimit: 512,
+		Ulimits:   []Ulimit{{Name: "nofile", Soft: 1024, Hard: 2048}},
+	}
+
+	merged := defaults.Merge(ResourceLimits{
+		Memory:  "8g",
+		Ulimits: []Ulimit{{Name: "nofile", Soft: 4096, Hard: 4096}},
+	})
+
+	if merged.Memory != "8g" || merged.PidsLimit != 512 {
+		t.Errorf("Expected memory 8g and pids limit 512. Got: %s", merged)
+	}
+
+	if len(merged.Ulimits) != 1 || merged.Ulimits[0].Hard != 4096 {
+		t.Errorf("Expected nofile ulimit to be overridden. Got: %v", merged.Ulimits)
+	}
+}
+
+func TestResourceLimitsCheck(t *testing.T) {
+	max := ResourceLimits{Memory: "8g", MemorySwap: "8g"}
+
+	if err := (ResourceLimits{Memory: "4g"}).Check(max); err != nil {
+		t.Errorf("Expected limits to be valid. Got: %s", err)
+	}
+
+	if err := (ResourceLimits{Memory: "16g"}).Check(max); err != ErrLimitsExceeded {
+		t.Errorf("Expected ErrLimitsExceeded. Got: %v", err)
+	}
+
+	if err := (ResourceLimits{MemorySwap: "-1"}).Check(max); err != ErrLimitsExceeded {
+		t.Errorf("Expected unlimited swap to exceed the maximum. Got: %v", err)
+	}
+
+	if err := (ResourceLimits{Memory: "lots"}).Check(max); err == nil {
+		t.Error("Expected an error for an invalid memory value")
+	}
+}
@@ -1,5 +1,11 @@
 package models
 
+import (
+	"time"
+
+	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
+)
+
 const (
 	// NotFoundError error from server
 	NotFoundError string = "Not found"
@@ -84,3 +90,133 @@ type Namespaceinfo struct {
 	Name   string   `json:"ns"`
 	Groups []string `json:"groups"`
 }
+
+// ScheduleInfo info of a schedule
+type ScheduleInfo struct {
+	ID         uint                      `json:"id"`
+	Name       string                    `json:"name"`
+	Cron       string                    `json:"cron"`
+	BuildType  libremotebuild.JobType    `json:"jobtype"`
+	UploadType libremotebuild.UploadType `json:"uploadtype"`
+	Priority   string                    `json:"priority"`
+	Paused     bool                      `json:"paused"`
+	LastRun    time.Time                 `json:"lastrun,omitempty"`
+	NextRun    time.Time                 `json:"nextrun"`
+}
+
+// ListSchedulesResponse list of schedules
+type ListSchedulesResponse struct {
+	Schedules []ScheduleInfo `json:"schedules"`
+}
+
+// JobInfo info of job
+type JobInfo struct {
+	libremotebuild.JobInfo
+	StateName      string        `json:"stateName"`
+	Attempts       int           `json:"attempts"`
+	FailedAttempts []AttemptInfo `json:"failedAttempts,omitempty"`
+	Timeline       *Timeline     `json:"timeline,omitempty"`
+	BatchID        uint          `json:"batch,omitempty"`
+	UserID         uint          `json:"userID,omitempty"`
+	Owner          string        `json:"owner,omitempty"` // Username of the creator
+}
+
+// AddBatchResponse response for a created batch
+type AddBatchResponse struct {
+	BatchID uint                            `json:"batch"`
+	Jobs    []libremotebuild.AddJobResponse `json:"jobs"`
+}
+
+// BatchInfo info of a batch
+type BatchInfo struct {
+	ID      uint           `json:"id"`
+	Created time.Time      `json:"created"`
+	States  map[string]int `json:"states"` // Count of jobs by state name
+	Jobs    []JobInfo      `json:"jobs,omitempty"`
+}
+
+// ListBatchesResponse list of batches
+type ListBatchesResponse struct {
+	Batches []BatchInfo `json:"batches"`
+}
+
+// Timeline stages of a job
+type Timeline struct {
+	Entries   []TimelineEntry            `json:"entries"`
+	Durations map[JobStage]time.Duration `json:"durations"` // Total time spent per stage
+}
+
+// TimelineEntry a stage of a job
+type TimelineEntry struct {
+	Stage    JobStage      `json:"stage"`
+	Reason   string        `json:"reason,omitempty"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+}
+
+// AttemptInfo info of a failed attempt
+type AttemptInfo struct {
+	Attempt int       `json:"attempt"`
+	Phase   JobPhase  `json:"phase"`
+	Error   string    `json:"error"`
+	Time    time.Time `json:"time"`
+}
+
+// ListJobsResponse list of queued jobs
+type ListJobsResponse struct {
+	Jobs []JobInfo `json:"jobs"`
+}
+
+// ArchivedLogsResponse a page of archived build logs
+type ArchivedLogsResponse struct {
+	Content string `json:"content"`
+	Next    int64  `json:"next"` // Offset of the next page
+	EOF     bool   `json:"eof"`
+}
+
+// UserInfo info of a user
+type UserInfo struct {
+	ID       uint      `json:"id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	Created  time.Time `json:"created"`
+}
+
+// ListUsersResponse list of users
+type ListUsersResponse struct {
+	Users []UserInfo `json:"users"`
+}
+
+// CreateTokenResponse response for creating an API token
+type CreateTokenResponse struct {
+	Token string `json:"token"` // Only available once
+}
+
+// TokenInfo info of an API token
+type TokenInfo struct {
+	Name     string     `json:"name"`
+	Scopes   []string   `json:"scopes"`
+	Created  time.Time  `json:"created"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	LastUsed *time.Time `json:"lastUsed,omitempty"`
+}
+
+// ListTokensResponse list of API tokens
+type ListTokensResponse struct {
+	Tokens []TokenInfo `json:"tokens"`
+}
+
+// SessionInfo info of a login session
+type SessionInfo struct {
+	ID        uint      `json:"id"`
+	MachineID string    `json:"machineID"`
+	Created   time.Time `json:"created"`
+	LastUsed  time.Time `json:"lastUsed"`
+	Requests  int64     `json:"requests"`
+	Current   bool      `json:"current"`
+}
+
+// ListSessionsResponse list of login sessions
+type ListSessionsResponse struct {
+	Sessions []SessionInfo `json:"sessions"`
+}
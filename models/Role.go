@@ -0,0 +1,70 @@
+package models
+
+import "strings"
+
+// Permission required to access a route. Each
+// permission includes all permissions below it
+type Permission uint8
+
+// Permissions
+const (
+	PermNone      Permission = iota // No permission required
+	PermJobRead                     // View jobs, logs and stats
+	PermJobCreate                   // Create and control jobs
+	PermAdmin                       // Manage users and the server
+)
+
+// Roles of users
+const (
+	RoleBuilder  uint = 0 // Can create jobs. Default for new users
+	RoleAdmin    uint = 1 // Can access all jobs and manage the server
+	RoleReadOnly uint = 2 // Can only view jobs
+)
+
+// Role a role users can have
+type Role struct {
+	ID         uint
+	Name       string
+	Permission Permission
+}
+
+// Roles all available roles
+var Roles = []Role{
+	{ID: RoleAdmin, Name: "admin", Permission: PermAdmin},
+	{ID: RoleBuilder, Name: "builder", Permission: PermJobCreate},
+	{ID: RoleReadOnly, Name: "read-only", Permission: PermJobRead},
+}
+
+// GetRole returns the role with the given ID
+func GetRole(id uint) (Role, bool) {
+	for _, role := range Roles {
+		if role.ID == id {
+			return role, true
+		}
+	}
+
+	return Role{}, false
+}
+
+// ParseRole returns the role with the given name
+func ParseRole(name string) (Role, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	for _, role := range Roles {
+		if role.Name == name {
+			return role, true
+		}
+	}
+
+	return Role{}, false
+}
+
+// RoleNames returns the names of all roles
+func RoleNames() []string {
+	names := make([]string, len(Roles))
+	for i := range Roles {
+		names[i] = Roles[i].Name
+	}
+
+	return names
+}
@@ -0,0 +1,39 @@
+package models
+
+import "testing"
+
+func TestUserHasPermission(t *testing.T) {
+	tests := []struct {
+		role       uint
+		permission Permission
+		expected   bool
+	}{
+		{RoleAdmin, PermAdmin, true},
+		{RoleAdmin, PermJobCreate, true},
+		{RoleBuilder, PermJobCreate, true},
+		{RoleBuilder, PermJobRead, true},
+		{RoleBuilder, PermAdmin, false},
+		{RoleReadOnly, PermJobRead, true},
+		{RoleReadOnly, PermJobCreate, false},
+		{100, PermJobRead, false},
+		{100, PermNone, true},
+	}
+
+	for _, test := range tests {
+		user := User{RoleID: test.role}
+		if got := user.HasPermission(test.permission); got != test.expected {
+			t.Errorf("Role %d, permission %d: expected %t. Got %t", test.role, test.permission, test.expected, got)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	role, ok := ParseRole(" Read-Only")
+	if !ok || role.ID != RoleReadOnly {
+		t.Errorf("Expected read-only role. Got %v", role)
+	}
+
+	if _, ok := ParseRole("root"); ok {
+		t.Error("Expected unknown role to fail")
+	}
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// Schedule a recurring build
+type Schedule struct {
+	gorm.Model
+
+	UserID uint  `sql:"index"`
+	User   *User `gorm:"association_autoupdate:false;association_autocreate:false"`
+
+	Name string
+	Cron string // Cron expression. See https://pkg.go.dev/github.com/robfig/cron/v3
+
+	BuildType     libremotebuild.JobType
+	UploadType    libremotebuild.UploadType
+	Argdata       string
+	SecretArgdata string
+	SecretArgs    string // Comma separated names of the secret args
+	DisableCcache bool
+	Priority      JobPriority
+
+	Paused  bool
+	LastRun time.Time
+	NextRun time.Time `sql:"index"`
+}
+
+// ParseCron parses a cron expression
+func ParseCron(expression string) (cron.Schedule, error) {
+	return cron.ParseStandard(expression)
+}
+
+// NewSchedule create a new schedule
+func NewSchedule(db *gorm.DB, schedule Schedule, args map[string]string) (*Schedule, error) {
+	var err error
+	schedule.Argdata, schedule.SecretArgdata, schedule.SecretArgs, err = encodeArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Calculate first run
+	if err = schedule.UpdateNextRun(time.Now()); err != nil {
+		return nil, err
+	}
+
+	// Save schedule
+	if err = db.Create(&schedule).Error; err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+// UpdateNextRun sets NextRun to the next
+// activation of the schedule after t
+func (schedule *Schedule) UpdateNextRun(t time.Time) error {
+	cronSchedule, err := ParseCron(schedule.Cron)
+	if err != nil {
+		return err
+	}
+
+	schedule.NextRun = cronSchedule.Next(t)
+	return nil
+}
+
+// GetArgs returns the args for jobs created by the schedule.
+// Secrets are missing if the schedule was imported without them
+func (schedule *Schedule) GetArgs() (map[string]string, error) {
+	args, err := parseArgs(schedule.Argdata)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := parseArgs(schedule.SecretArgdata)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(schedule.SecretArgs) > 0 {
+		if missing := MissingArgs(secrets, strings.Split(schedule.SecretArgs, ",")); len(missing) > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrMissingSecretArgs, strings.Join(missing, ", "))
+		}
+	}
+
+	if args == nil {
+		args = make(map[string]string)
+	}
+	for k, v := range secrets {
+		args[k] = v
+	}
+
+	return args, nil
+}
+
+// ToScheduleInfo return ScheduleInfo by schedule
+func (schedule Schedule) ToScheduleInfo() ScheduleInfo {
+	return ScheduleInfo{
+		ID:         schedule.ID,
+		Name:       schedule.Name,
+		Cron:       schedule.Cron,
+		BuildType:  schedule.BuildType,
+		UploadType: schedule.UploadType,
+		Priority:   schedule.Priority.String(),
+		Paused:     schedule.Paused,
+		LastRun:    schedule.LastRun,
+		NextRun:    schedule.NextRun,
+	}
+}
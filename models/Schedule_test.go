@@ -0,0 +1,27 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNextRun(t *testing.T) {
+	schedule := Schedule{Cron: "30 2 * * *"}
+	now := time.Date(2020, 11, 20, 3, 0, 0, 0, time.Local)
+
+	if err := schedule.UpdateNextRun(now); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := time.Date(2020, 11, 21, 2, 30, 0, 0, time.Local); !schedule.NextRun.Equal(expected) {
+		t.Errorf("Expected next run at %s. Got: %s", expected, schedule.NextRun)
+	}
+}
+
+func TestScheduleInvalidCron(t *testing.T) {
+	schedule := Schedule{Cron: "every night"}
+
+	if err := schedule.UpdateNextRun(time.Now()); err == nil {
+		t.Error("Expected an error for an invalid cron expression")
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"strings"
 
-	"github.com/JojiiOfficial/gaw"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
@@ -17,18 +16,34 @@ type User struct {
 	RoleID   uint `sql:"index"`
 }
 
-// Login login user
-func (user *User) Login(db *gorm.DB, machineID string) (*LoginSession, error) {
+// Login login user. The password of user has to be the plain password
+func (user *User) Login(db *gorm.DB, config *Config, machineID string) (*LoginSession, error) {
 	// Truncat machineID if too big
 	if len(machineID) > 100 {
 		machineID = ""
 	}
 
+	password := user.Password
+	user.Username = user.GetUsername()
+	user.Password = ""
+
 	// Return if user not exists
-	if has, err := user.Has(db, true); !has {
+	if has, err := user.Has(db, false); !has {
 		return nil, err
 	}
 
+	ok, rehash := user.CheckPassword(password, config.Server.PasswordCost)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Upgrade old or outdated hashes
+	if rehash {
+		if err := user.SetPassword(db, password, config.Server.PasswordCost); err != nil {
+			logrus.Error(err)
+		}
+	}
+
 	// Clean old sessions for user + machineID
 	if err := user.cleanOldSessions(db, machineID); err != nil {
 		logrus.Error(err)
@@ -68,14 +83,34 @@ func (user User) Register(db *gorm.DB, config *Config) error {
 		return ErrorUserAlreadyExists
 	}
 
+	hash, err := HashPassword(user.Password, config.Server.PasswordCost)
+	if err != nil {
+		return err
+	}
+
 	user = User{
-		Password: gaw.SHA512(user.GetUsername() + user.Password),
+		Password: hash,
 		Username: user.GetUsername(),
 	}
 
 	return db.Create(&user).Error
 }
 
+// SetPassword hashes and saves a new password for the user
+func (user *User) SetPassword(db *gorm.DB, password string, cost int) error {
+	hash, err := HashPassword(password, cost)
+	if err != nil {
+		return err
+	}
+
+	if err = db.Model(user).Update("password", hash).Error; err != nil {
+		return err
+	}
+
+	user.Password = hash
+	return nil
+}
+
 // Has return true if user exists
 func (user *User) Has(db *gorm.DB, checkPass bool) (bool, error) {
 	pass := ""
@@ -94,6 +129,126 @@ func (user *User) Has(db *gorm.DB, checkPass bool) (bool, error) {
 	return true, nil
 }
 
+// IsAdmin returns true if the user is an admin
+func (user *User) IsAdmin() bool {
+	return user.RoleID == RoleAdmin
+}
+
+// HasPermission returns true if the role of
+// the user grants the permission
+func (user *User) HasPermission(permission Permission) bool {
+	role, ok := GetRole(user.RoleID)
+	if !ok {
+		return permission == PermNone
+	}
+
+	return permission <= role.Permission
+}
+
+// GetRoleName returns the name of the role of the user
+func (user *User) GetRoleName() string {
+	if role, ok := GetRole(user.RoleID); ok {
+		return role.Name
+	}
+
+	return "unknown"
+}
+
+// SetRole sets the role of the user with the given name.
+// New admins get the jobs without owner
+func SetRole(db *gorm.DB, username string, role Role) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var user User
+		err := tx.Where("username = ?", strings.ToLower(username)).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = tx.Model(&user).Update("role_id", role.ID).Error; err != nil {
+			return err
+		}
+
+		if role.ID == RoleAdmin {
+			return AssignOwnerlessJobs(tx, user.ID)
+		}
+
+		return nil
+	})
+}
+
+// AssignOwnerlessJobs assigns the jobs and batches of versions
+// without job owners to the user. Those are only left if no
+// admin existed when the db was migrated
+func AssignOwnerlessJobs(db *gorm.DB, userID uint) error {
+	for _, model := range []interface{}{&Job{}, &Batch{}} {
+		err := db.Unscoped().Model(model).
+			Where("user_id = 0 OR user_id IS NULL").
+			Update("user_id", userID).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteUser deletes the user with the given name, its
+// schedules and immediately kills all of its sessions and tokens
+func DeleteUser(db *gorm.DB, username string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var user User
+		err := tx.Where("username = ?", strings.ToLower(username)).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err = RevokeSessions(tx, user.ID); err != nil {
+			return err
+		}
+
+		if err = tx.Unscoped().Where("user_id = ?", user.ID).Delete(&APIToken{}).Error; err != nil {
+			return err
+		}
+
+		if err = tx.Unscoped().Where("user_id = ?", user.ID).Delete(&Schedule{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&user).Error
+	})
+}
+
+// GetUsers returns all users
+func GetUsers(db *gorm.DB) ([]User, error) {
+	var users []User
+	if err := db.Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetUsernames returns the usernames of the users by their ID
+func GetUsernames(db *gorm.DB, ids []uint) (map[uint]string, error) {
+	var users []User
+	if err := db.Select("id", "username").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	names := make(map[uint]string, len(users))
+	for _, user := range users {
+		names[user.ID] = user.Username
+	}
+
+	return names, nil
+}
+
 // GetUsername Gets username of user
 func (user *User) GetUsername() string {
 	return strings.ToLower(user.Username)
@@ -2,6 +2,10 @@ package services
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/RemoteBuild/Remotebuild/models"
@@ -32,10 +36,46 @@ func (cs *CleanupService) Start() {
 func (cs *CleanupService) run() {
 	for {
 		cs.deleteUnusedSessions()
+		cs.deleteExpiredSessions()
+		cs.deleteExpiredTokens()
+		cs.deleteOldLogs()
 		time.Sleep(1 * time.Hour)
 	}
 }
 
+// Deletes archived build logs older than the configured retention
+func (cs *CleanupService) deleteOldLogs() {
+	dir := cs.config.Server.BuildLogs.Dir
+	retention := cs.config.Server.BuildLogs.Retention
+	if len(dir) == 0 || retention <= 0 {
+		return
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	deleted := 0
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".log.gz") || time.Since(file.ModTime()) < retention {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(dir, file.Name())); err != nil {
+			log.Warn(err)
+			continue
+		}
+
+		deleted++
+	}
+
+	if deleted > 0 {
+		log.Infof("Deleted %d old build logs", deleted)
+	}
+}
+
 // Deletes unused sessions after in config specified duration
 func (cs *CleanupService) deleteUnusedSessions() {
 	// Delete where requests = 0 and creation > specified allowed time
@@ -56,7 +96,37 @@ func (cs *CleanupService) deleteUnusedSessions() {
 	}
 }
 
+// Deletes sessions exceeding their idle timeout or lifetime
+func (cs *CleanupService) deleteExpiredSessions() {
+	deleted, err := models.DeleteExpiredSessions(cs.db, cs.config)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if deleted > 0 {
+		log.Infof("Deleted %d expired sessions", deleted)
+	}
+}
+
+// Deletes expired API tokens
+func (cs *CleanupService) deleteExpiredTokens() {
+	deleted, err := models.DeleteExpiredAPITokens(cs.db)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if deleted > 0 {
+		log.Infof("Deleted %d expired API tokens", deleted)
+	}
+}
+
 // just debug things
 func (cs *CleanupService) debug() {
 	log.Debugf("Deleting unused sessions after %s", cs.config.Server.DeleteUnusedSessionsAfter.String())
+
+	if len(cs.config.Server.BuildLogs.Dir) > 0 && cs.config.Server.BuildLogs.Retention > 0 {
+		log.Debugf("Deleting build logs after %s", cs.config.Server.BuildLogs.Retention.String())
+	}
 }
@@ -1,6 +1,9 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"sort"
 	"sync"
 	"time"
@@ -11,26 +14,56 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrJobNotInQueue if a job is not in the queue
+	ErrJobNotInQueue = errors.New("Job not in queue")
+
+	// ErrJobIsRunning if a running job should be moved
+	ErrJobIsRunning = errors.New("Job is already running")
+
+	// ErrInvalidMoveAction if an unknown move action was passed
+	ErrInvalidMoveAction = errors.New("Invalid move action")
+
+	// ErrQueueDraining if a job is added while the queue is draining
+	ErrQueueDraining = errors.New("Server is draining and doesn't accept new jobs")
+)
+
 // JobQueue a queue for jobs
 type JobQueue struct {
 	db     *gorm.DB
 	config *models.Config
 
-	jobs         []JobQueueItem
+	jobs         []*JobQueueItem
 	mx           sync.RWMutex
-	stopped      chan bool
-	currJob      *JobQueueItem
+	stopped      chan struct{}
+	stopOnce     sync.Once
+	draining     chan struct{}          // Closed when the queue starts draining
+	running      map[uint]*JobQueueItem // Currently running jobs by JobID
+	workers      chan struct{}          // Limits the count of parallel jobs
+	wake         chan struct{}          // Notifies the dispatcher about changes
+	runJob       func(*JobQueueItem)    // Runs the job of an item
+	wg           sync.WaitGroup
 	getContainer ContainerGetter
 }
 
 // NewJobQueue create a new JobQueue
 func NewJobQueue(db *gorm.DB, config *models.Config, getContainer ContainerGetter) *JobQueue {
+	maxParallel := config.Server.Jobs.MaxParallel
+	if maxParallel < 1 {
+		maxParallel = 1
+	}
+
 	queue := &JobQueue{
 		db:           db,
 		config:       config,
 		getContainer: getContainer,
-		stopped:      make(chan bool, 1),
+		stopped:      make(chan struct{}),
+		draining:     make(chan struct{}),
+		running:      make(map[uint]*JobQueueItem),
+		workers:      make(chan struct{}, maxParallel),
+		wake:         make(chan struct{}, 1),
 	}
+	queue.runJob = queue.runItem
 
 	// Load Queue
 	err := queue.Load()
@@ -43,7 +76,7 @@ func NewJobQueue(db *gorm.DB, config *models.Config, getContainer ContainerGette
 
 // Load queue from Db
 func (jq *JobQueue) Load() error {
-	var jobs []JobQueueItem
+	var jobs []*JobQueueItem
 
 	// Load unfinished jobs
 	err := jq.db.Model(&JobQueueItem{}).
@@ -55,7 +88,8 @@ func (jq *JobQueue) Load() error {
 		return err
 	}
 
-	var jobsToUse []JobQueueItem
+	var jobsToUse []*JobQueueItem
+	building := make(map[uint]*JobQueueItem)
 
 	for i := range jobs {
 		// Init Job
@@ -67,10 +101,15 @@ func (jq *JobQueue) Load() error {
 
 		jobState := jobs[i].Job.GetState()
 
+		if jobs[i].Job.BuildJob.State == libremotebuild.JobRunning {
+			building[jobs[i].JobID] = jobs[i]
+		}
+
 		// Set running jobs to waiting
 		if jobState == libremotebuild.JobRunning {
 			jobState = libremotebuild.JobWaiting
 			jobs[i].Job.SetState(libremotebuild.JobWaiting)
+			jobs[i].Job.SetStage(models.StageQueued, "Server restarted")
 		}
 
 		// Ignore cancelled/failed/finished jobs
@@ -81,37 +120,206 @@ func (jq *JobQueue) Load() error {
 
 	jq.jobs = jobsToUse
 	log.Infof("Loaded %d Jobs from old queue", len(jobsToUse))
+
+	// Handle containers and files of the previous run
+	jq.reconcile(building)
 	return nil
 }
 
+// JobOptions options for creating a new job
+type JobOptions struct {
+	Type       libremotebuild.JobType
+	UploadType libremotebuild.UploadType
+	Args       map[string]string
+	UseCcache  bool
+	Priority   models.JobPriority
+	DependsOn  []uint                // Jobs which have to be done before the new job
+	Retry      *models.RetryPolicy   // Use the default policy if nil
+	Timeout    time.Duration         // Use the default build timeout if 0
+	Limits     models.ResourceLimits // Overrides the default container limits
+	Image      string                // Use the configured image if empty
+	Force      bool                  // Build even if the result exists already
+	UserID     uint                  // Owner of the job
+
+	DependOnAnyJob bool // Allow depending on jobs of other users
+}
+
 // AddNewJob create job and add to queue
-func (jq *JobQueue) AddNewJob(db *gorm.DB, Type libremotebuild.JobType, uploadType libremotebuild.UploadType, args map[string]string, useCcache bool) (*JobQueueItem, error) {
+func (jq *JobQueue) AddNewJob(db *gorm.DB, options JobOptions) (*JobQueueItem, error) {
+	items, err := jq.addNewJobs(db, false, []JobOptions{options})
+	if err != nil {
+		return nil, err
+	}
+
+	return items[0], nil
+}
+
+// AddBatch creates jobs with a shared batch ID and adds them
+// to the queue. Either all or none of the jobs get created
+func (jq *JobQueue) AddBatch(db *gorm.DB, options []JobOptions) ([]*JobQueueItem, error) {
+	return jq.addNewJobs(db, true, options)
+}
+
+// Create jobs in one transaction and add them to the queue
+func (jq *JobQueue) addNewJobs(db *gorm.DB, batch bool, options []JobOptions) ([]*JobQueueItem, error) {
+	if jq.IsDraining() {
+		return nil, ErrQueueDraining
+	}
+
+	var items []*JobQueueItem
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var batchID uint
+		if batch {
+			b := models.Batch{UserID: options[0].UserID}
+			if err := tx.Create(&b).Error; err != nil {
+				return err
+			}
+			batchID = b.ID
+		}
+
+		for i := range options {
+			item, err := jq.createJob(tx, batchID, options[i])
+			if item != nil {
+				items = append(items, item)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		// Remove build dirs of the rolled back jobs
+		for _, item := range items {
+			if err := os.RemoveAll(item.Job.DataDir); err != nil {
+				log.Warn(err)
+			}
+		}
+
+		return nil, err
+	}
+
+	// Jobs were created using the transaction
+	for _, item := range items {
+		if err = item.Job.Init(jq.db, jq.config); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = jq.enqueue(items...); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Create a job and its queue item
+func (jq *JobQueue) createJob(db *gorm.DB, batchID uint, options JobOptions) (*JobQueueItem, error) {
 	// Get image
-	image, err := jq.getContainer(Type)
+	image := options.Image
+	if len(image) == 0 {
+		var err error
+		if image, err = jq.getContainer(options.Type); err != nil {
+			return nil, err
+		}
+	}
+
+	// Verify dependencies
+	dependsOn, err := jq.checkDependenciesExist(db, options)
 	if err != nil {
 		return nil, err
 	}
 
+	// Use default retry policy if not set
+	retryPolicy := jq.config.GetRetryPolicy()
+	if options.Retry != nil {
+		retryPolicy = *options.Retry
+	}
+
 	// Create job
 	job, err := models.NewJob(db, jq.config, image, models.BuildJob{
-		Type: Type,
+		Type:    options.Type,
+		Timeout: options.Timeout,
+		Limits:  options.Limits,
 	}, models.UploadJob{
-		Type: uploadType,
-	}, args, useCcache)
+		Type: options.UploadType,
+	}, options.Args, options.UseCcache, retryPolicy)
 
 	if err != nil {
 		return nil, err
 	}
 
-	// add job to queue
-	return jq.AddJob(job)
+	item := &JobQueueItem{
+		JobID:    job.ID,
+		Job:      job,
+		Priority: options.Priority,
+	}
+
+	// Assign owner, batch and force flag
+	job.UserID = options.UserID
+	job.BatchID = batchID
+	job.Force = options.Force
+	if err = db.Model(job).Select("user_id", "batch_id", "force").Updates(job).Error; err != nil {
+		return item, err
+	}
+
+	// Save dependencies
+	if err = models.SaveDependencies(db, job.ID, dependsOn); err != nil {
+		return item, err
+	}
+	job.DependsOn = dependsOn
+
+	return item, db.Create(item).Error
+}
+
+// Return the unique IDs of dependencies or an error if one of
+// the dependencies doesn't exist or belongs to another user
+func (jq *JobQueue) checkDependenciesExist(db *gorm.DB, options JobOptions) ([]uint, error) {
+	if len(options.DependsOn) == 0 {
+		return nil, nil
+	}
+
+	// Remove duplicates
+	var ids []uint
+	seen := make(map[uint]bool)
+	for _, id := range options.DependsOn {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	query := db.Model(&models.Job{}).Where("id IN ?", ids)
+	if !options.DependOnAnyJob {
+		query = query.Where("user_id = ?", options.UserID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, err
+	}
+
+	if int(count) != len(ids) {
+		return nil, models.ErrDependencyNotFound
+	}
+
+	return ids, nil
 }
 
-// AddJob to a jobqueue
-func (jq *JobQueue) AddJob(job *models.Job) (*JobQueueItem, error) {
+// AddJob to a jobqueue. The job gets inserted
+// behind the last job with an equal or higher priority
+func (jq *JobQueue) AddJob(job *models.Job, priority models.JobPriority) (*JobQueueItem, error) {
 	item := &JobQueueItem{
-		JobID: job.ID,
-		Job:   job,
+		JobID:    job.ID,
+		Job:      job,
+		Priority: priority,
+	}
+
+	if jq.IsDraining() {
+		return nil, ErrQueueDraining
 	}
 
 	// Insert Item
@@ -120,20 +328,139 @@ func (jq *JobQueue) AddJob(job *models.Job) (*JobQueueItem, error) {
 		return nil, err
 	}
 
-	// Use ID as Position
-	item.Position = item.ID
-	err = jq.db.Save(item).Error
-	if err != nil {
+	if err = jq.enqueue(item); err != nil {
 		return nil, err
 	}
 
+	return item, nil
+}
+
+// Insert saved items into the queue. Each item gets inserted
+// behind the last job with an equal or higher priority
+func (jq *JobQueue) enqueue(items ...*JobQueueItem) error {
 	jq.mx.Lock()
 	defer jq.mx.Unlock()
 
-	jq.jobs = append(jq.jobs, *item)
+	jq.sortPosition()
 
-	log.Debugf("Job %d added", item.ID)
-	return item, nil
+	for _, item := range items {
+		// Find the position to insert the job at
+		pos := len(jq.jobs)
+		for pos > 0 && jq.jobs[pos-1].Priority < item.Priority && !jq.isRunning(jq.jobs[pos-1]) {
+			pos--
+		}
+
+		jq.insertAt(pos, item)
+		log.Debugf("Job %d added with priority %s", item.JobID, item.Priority)
+	}
+
+	// Persist new order
+	if err := jq.savePositions(); err != nil {
+		return err
+	}
+
+	jq.notify()
+	return nil
+}
+
+// MoveJob moves a waiting job to the front or back of the
+// queue or before/after the job with the ID targetID
+func (jq *JobQueue) MoveJob(jobID uint, action models.JobMoveAction, targetID uint) error {
+	jq.mx.Lock()
+	defer jq.mx.Unlock()
+
+	jq.sortPosition()
+
+	index := jq.indexOf(jobID)
+	if index == -1 {
+		return ErrJobNotInQueue
+	}
+
+	item := jq.jobs[index]
+	if jq.isRunning(item) {
+		return ErrJobIsRunning
+	}
+
+	// Remove item from queue before searching the new position
+	jq.jobs = append(jq.jobs[:index], jq.jobs[index+1:]...)
+
+	var pos int
+	switch action {
+	case models.MoveFront:
+		pos = 0
+	case models.MoveBack:
+		pos = len(jq.jobs)
+	case models.MoveBefore, models.MoveAfter:
+		pos = jq.indexOf(targetID)
+		if pos == -1 {
+			jq.insertAt(index, item)
+			return ErrJobNotInQueue
+		}
+
+		if action == models.MoveAfter {
+			pos++
+		}
+	default:
+		jq.insertAt(index, item)
+		return ErrInvalidMoveAction
+	}
+
+	// Waiting jobs can't be moved before running jobs
+	for pos < len(jq.jobs) && jq.isRunning(jq.jobs[pos]) {
+		pos++
+	}
+
+	jq.insertAt(pos, item)
+	return jq.savePositions()
+}
+
+// insertAt inserts item at index pos. jq.mx must be locked
+func (jq *JobQueue) insertAt(pos int, item *JobQueueItem) {
+	jq.jobs = append(jq.jobs, nil)
+	copy(jq.jobs[pos+1:], jq.jobs[pos:])
+	jq.jobs[pos] = item
+}
+
+// indexOf returns the index of the job with jobID
+// or -1 if not found. jq.mx must be locked
+func (jq *JobQueue) indexOf(jobID uint) int {
+	for i := range jq.jobs {
+		if jq.jobs[i].JobID == jobID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// isRunning return true if item is running. jq.mx must be locked
+func (jq *JobQueue) isRunning(item *JobQueueItem) bool {
+	_, running := jq.running[item.JobID]
+	return running
+}
+
+// savePositions updates the position of all items in the
+// queue to match their index and saves them. jq.mx must be locked
+func (jq *JobQueue) savePositions() error {
+	return jq.db.Transaction(func(tx *gorm.DB) error {
+		for i, item := range jq.jobs {
+			newPos := uint(i + 1)
+			if item.Position == newPos {
+				continue
+			}
+
+			err := tx.Model(&JobQueueItem{}).
+				Where("id=?", item.ID).
+				Update("position", newPos).Error
+			if err != nil {
+				return err
+			}
+
+			item.Position = newPos
+		}
+
+		return nil
+	})
 }
 
 // Start the queue async
@@ -143,21 +470,33 @@ func (jq *JobQueue) Start() {
 
 // Run the queue
 func (jq *JobQueue) Run() {
-	log.Info("Starting JobQueue")
+	log.Infof("Starting JobQueue with %d worker(s)", cap(jq.workers))
 
 	for {
-		job := jq.getNextJob()
-
-		jq.run(job)
-		jq.currJob = nil
-
-		// Only continue if not stopped
+		// Wait for a free worker
 		select {
+		case jq.workers <- struct{}{}:
 		case <-jq.stopped:
 			log.Info("Stopped JobQueue")
 			return
-		default:
 		}
+
+		job := jq.getNextJob()
+		if job == nil {
+			// Queue was stopped while waiting
+			<-jq.workers
+			log.Info("Stopped JobQueue")
+			return
+		}
+
+		go func() {
+			defer func() {
+				<-jq.workers
+				jq.wg.Done()
+			}()
+
+			jq.run(job)
+		}()
 	}
 }
 
@@ -167,23 +506,24 @@ func (jq *JobQueue) run(jqi *JobQueueItem) {
 	// state it exited
 	defer func() {
 		// Delete jqi
-		if err := jq.db.Delete(&jqi).Error; err != nil {
+		if err := jq.db.Delete(jqi).Error; err != nil {
 			log.Warn(err)
 		}
 
-		jqi.Deleted = true
-		jq.RemoveJob(jqi.ID)
+		jq.RemoveJob(jqi.JobID)
 	}()
 
+	jq.runJob(jqi)
+}
+
+// Load and run the job of a QueueItem
+func (jq *JobQueue) runItem(jqi *JobQueueItem) {
 	// Get Job
 	if err := jqi.Load(jq.db, jq.config); err != nil {
 		log.Error(err)
 		return
 	}
 
-	jq.currJob = jqi
-	jqi.RunningSince = time.Now()
-
 	// Run job and log errors
 	if err := jqi.Job.Run(); err != nil {
 		if err != models.ErrorJobCancelled {
@@ -198,67 +538,313 @@ func (jq *JobQueue) sortPosition() {
 	sort.Sort(SortByPosition(jq.jobs))
 }
 
+// notify the dispatcher about a change of the queue
+func (jq *JobQueue) notify() {
+	select {
+	case jq.wake <- struct{}{}:
+	default:
+	}
+}
+
+// getNextJob waits for the next job which isn't
+// running yet and marks it as running. Returns
+// nil if the queue was stopped while waiting
 func (jq *JobQueue) getNextJob() *JobQueueItem {
-	for len(jq.jobs) == 0 {
-		time.Sleep(1 * time.Second)
+	for {
+		item, failed := jq.pickNextJob(jq.loadDependencies())
+
+		// Fail jobs with failed dependencies
+		for failedItem, reason := range failed {
+			jq.failJob(failedItem, reason)
+		}
+
+		if item != nil {
+			return item
+		}
+
+		// Failed jobs might affect other jobs
+		if len(failed) > 0 {
+			continue
+		}
+
+		select {
+		case <-jq.stopped:
+			return nil
+		case <-jq.draining:
+			return nil
+		case <-jq.wake:
+		}
+	}
+}
+
+// loadDependencies loads the jobs which waiting items depend on
+// and which aren't queued anymore. Jobs which don't exist map
+// to nil. Called without holding jq.mx, so the queries don't
+// block the queue
+func (jq *JobQueue) loadDependencies() map[uint]*models.Job {
+	var ids []uint
+
+	jq.mx.RLock()
+	for _, item := range jq.jobs {
+		if item.Deleted || item.Job == nil || jq.isRunning(item) {
+			continue
+		}
+
+		for _, dependencyID := range item.Job.DependsOn {
+			if i := jq.indexOf(dependencyID); i == -1 || jq.jobs[i].Deleted {
+				ids = append(ids, dependencyID)
+			}
+		}
+	}
+	jq.mx.RUnlock()
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var jobs []models.Job
+	err := jq.db.Model(&models.Job{}).
+		Preload("BuildJob").
+		Preload("UploadJob").
+		Where("id IN ?", ids).
+		Find(&jobs).Error
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+
+	dependencies := make(map[uint]*models.Job, len(ids))
+	for _, id := range ids {
+		dependencies[id] = nil
+	}
+	for i := range jobs {
+		dependencies[jobs[i].ID] = &jobs[i]
+	}
+
+	return dependencies
+}
+
+// pickNextJob returns the first job which can be run and
+// marks it as running. Jobs which can never run are
+// returned as failed, with the reason as value.
+// dependencies are the jobs returned by loadDependencies
+func (jq *JobQueue) pickNextJob(dependencies map[uint]*models.Job) (*JobQueueItem, map[*JobQueueItem]string) {
+	jq.mx.Lock()
+	defer jq.mx.Unlock()
+
+	// Don't start new jobs while draining
+	if jq.isDraining() {
+		return nil, nil
 	}
 
 	jq.sortPosition()
-	return &jq.jobs[0]
+
+	failed := make(map[*JobQueueItem]string)
+
+	for _, item := range jq.jobs {
+		if item.Deleted || jq.isRunning(item) {
+			continue
+		}
+
+		// Check dependencies
+		done, reason := jq.dependenciesDone(item, dependencies)
+		if len(reason) > 0 {
+			failed[item] = reason
+			continue
+		}
+
+		if done {
+			// Count the job as running while jq.mx is locked,
+			// so Drain can't miss it
+			jq.running[item.JobID] = item
+			jq.wg.Add(1)
+			item.RunningSince = time.Now()
+			return item, failed
+		}
+	}
+
+	return nil, failed
+}
+
+// dependenciesDone returns true if all dependencies of item
+// are done. If a dependency can't succeed anymore, the
+// reason is returned. jq.mx must be locked
+func (jq *JobQueue) dependenciesDone(item *JobQueueItem, dependencies map[uint]*models.Job) (bool, string) {
+	if item.Job == nil || len(item.Job.DependsOn) == 0 {
+		return true, ""
+	}
+
+	var pending []uint
+
+	for _, dependencyID := range item.Job.DependsOn {
+		// Job is still queued
+		if i := jq.indexOf(dependencyID); i != -1 && !jq.jobs[i].Deleted {
+			pending = append(pending, dependencyID)
+			continue
+		}
+
+		// Not loaded, check again on the next run
+		dependency, loaded := dependencies[dependencyID]
+		if !loaded {
+			pending = append(pending, dependencyID)
+			continue
+		}
+
+		if dependency == nil {
+			return false, fmt.Sprintf("Dependency %d not found", dependencyID)
+		}
+
+		if dependency.BuildJob == nil || dependency.UploadJob == nil {
+			return false, fmt.Sprintf("Dependency %d is invalid", dependencyID)
+		}
+
+		switch dependency.GetState() {
+		case libremotebuild.JobDone:
+		case libremotebuild.JobCancelled:
+			return false, fmt.Sprintf("Dependency %d was cancelled", dependencyID)
+		case libremotebuild.JobFailed:
+			return false, fmt.Sprintf("Dependency %d failed", dependencyID)
+		case models.JobTimedOut:
+			return false, fmt.Sprintf("Dependency %d timed out", dependencyID)
+		default:
+			// Job is not in queue anymore and can't finish
+			return false, fmt.Sprintf("Dependency %d didn't finish", dependencyID)
+		}
+	}
+
+	// Don't check finished dependencies again
+	item.Job.DependsOn = pending
+
+	return len(pending) == 0, ""
+}
+
+// failJob fails a job which was never started and removes it from the queue
+func (jq *JobQueue) failJob(jqi *JobQueueItem, reason string) {
+	log.Infof("Job %d failed: %s", jqi.JobID, reason)
+
+	if err := jqi.Load(jq.db, jq.config); err != nil {
+		log.Error(err)
+	} else {
+		jqi.Job.Fail(reason)
+	}
+
+	// Delete jqi
+	if err := jq.db.Delete(jqi).Error; err != nil {
+		log.Warn(err)
+	}
+
+	jq.RemoveJob(jqi.JobID)
 }
 
 // FindJob find job in queue
 func (jq *JobQueue) FindJob(jobID uint) *JobQueueItem {
+	jq.mx.RLock()
+	defer jq.mx.RUnlock()
+
 	// Find job in Queue slice
-	for j := range jq.jobs {
-		if jq.jobs[j].JobID == jobID {
-			return &jq.jobs[j]
-		}
+	if i := jq.indexOf(jobID); i != -1 {
+		return jq.jobs[i]
 	}
 
 	return nil
 }
 
+// IsRunning return true if the job with the given ID is running
+func (jq *JobQueue) IsRunning(jobID uint) bool {
+	jq.mx.RLock()
+	defer jq.mx.RUnlock()
+
+	_, running := jq.running[jobID]
+	return running
+}
+
+// GetRunningJobs return all currently running jobs
+func (jq *JobQueue) GetRunningJobs() []*JobQueueItem {
+	jq.mx.RLock()
+	defer jq.mx.RUnlock()
+
+	jobs := make([]*JobQueueItem, 0, len(jq.running))
+	for _, item := range jq.running {
+		jobs = append(jobs, item)
+	}
+
+	return jobs
+}
+
 // RemoveJob remove item from jobQueue
 func (jq *JobQueue) RemoveJob(jobID uint) {
-	if jq.currJob != nil && jq.currJob.Job.ID == jobID {
-		jq.currJob = nil
-	}
+	jq.mx.Lock()
+	jq.removeJob(jobID)
+	jq.mx.Unlock()
 
-	i := -1
+	// Jobs depending on the removed job might be runnable now
+	jq.notify()
+}
 
-	// Find job in Queue slice
-	for j := range jq.jobs {
-		if jq.jobs[j].JobID == jobID {
-			i = j
-			break
-		}
+// removeJob jq.mx must be locked
+func (jq *JobQueue) removeJob(jobID uint) {
+	delete(jq.running, jobID)
+
+	// Remove job from actual slice
+	if i := jq.indexOf(jobID); i != -1 {
+		jq.jobs[i].Deleted = true
+		jq.jobs = append(jq.jobs[:i], jq.jobs[i+1:]...)
 	}
+}
 
+// CancelJob cancels a job. Running jobs get removed
+// from the queue by the queue after they exited
+func (jq *JobQueue) CancelJob(jobID uint) error {
 	jq.mx.Lock()
-	defer jq.mx.Unlock()
 
-	// Remove job from actual slice
-	jq.jobs[len(jq.jobs)-1], jq.jobs[i] = jq.jobs[i], jq.jobs[len(jq.jobs)-1]
-	jq.jobs = jq.jobs[:len(jq.jobs)-1]
+	i := jq.indexOf(jobID)
+	if i == -1 {
+		jq.mx.Unlock()
+		return ErrJobNotInQueue
+	}
+
+	item := jq.jobs[i]
+	running := jq.isRunning(item)
+
+	// Remove waiting jobs right away, so they can't get started anymore
+	if !running {
+		jq.removeJob(jobID)
+	}
+	jq.mx.Unlock()
+
+	item.Job.Cancel()
+
+	if !running {
+		if err := jq.db.Delete(item).Error; err != nil {
+			log.Warn(err)
+		}
+
+		jq.notify()
+	}
+
+	log.Info("Cancelled Job ", jobID)
+	return nil
 }
 
-// GetJobQueuePos position of job in the queue
+// GetJobQueuePos returns the stored, 1-based position
+// of a job in the queue or -1 if it's not queued
 func (jq *JobQueue) GetJobQueuePos(jiq *JobQueueItem) int {
-	jq.sortPosition()
+	jq.mx.RLock()
+	defer jq.mx.RUnlock()
 
-	for i := range jq.jobs {
-		if jq.jobs[i].ID == jiq.ID {
-			return i
-		}
+	if i := jq.indexOf(jiq.JobID); i != -1 {
+		return int(jq.jobs[i].Position)
 	}
 
 	return -1
 }
 
 // GetJobs return jobs in queue
-func (jq *JobQueue) GetJobs() []JobQueueItem {
-	var validJobs []JobQueueItem
+func (jq *JobQueue) GetJobs() []*JobQueueItem {
+	jq.mx.RLock()
+	defer jq.mx.RUnlock()
+
+	var validJobs []*JobQueueItem
 
 	// Build slice with non-deleted jobs
 	for i := range jq.jobs {
@@ -267,18 +853,69 @@ func (jq *JobQueue) GetJobs() []JobQueueItem {
 		}
 	}
 
+	sort.Sort(SortByPosition(validJobs))
+
+	return validJobs
+}
+
+// Drain stops accepting and starting new jobs.
+// Running jobs continue
+func (jq *JobQueue) Drain() {
 	jq.mx.Lock()
 	defer jq.mx.Unlock()
 
-	sort.Sort(SortByPosition(validJobs))
+	if !jq.isDraining() {
+		log.Info("Draining JobQueue")
+		close(jq.draining)
+	}
+}
 
-	return validJobs
+// IsDraining returns true if the queue is draining
+func (jq *JobQueue) IsDraining() bool {
+	jq.mx.RLock()
+	defer jq.mx.RUnlock()
+
+	return jq.isDraining()
+}
+
+// isDraining jq.mx must be locked
+func (jq *JobQueue) isDraining() bool {
+	select {
+	case <-jq.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// AwaitRunning waits until all running jobs are done. Returns
+// false if they didn't finish within timeout. Should only be
+// called after Drain
+func (jq *JobQueue) AwaitRunning(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		jq.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
 }
 
+// Stop the queue and cancel all running jobs
 func (jq *JobQueue) stop() {
-	jq.stopped <- true
+	jq.stopOnce.Do(func() {
+		close(jq.stopped)
+	})
 
-	if jq.currJob != nil {
-		jq.currJob.Job.Cancel()
+	for _, item := range jq.GetRunningJobs() {
+		item.Job.Cancel()
 	}
+
+	// Wait for all workers to exit
+	jq.wg.Wait()
 }
@@ -14,14 +14,15 @@ type JobQueueItem struct {
 	JobID uint        `sql:"index"`
 	Job   *models.Job `gorm:"association_autoupdate:false;association_autocreate:false"`
 
-	Position uint // The position in the Queue
+	Position uint               // The position in the Queue
+	Priority models.JobPriority // Priority used for inserting new jobs
 
 	RunningSince time.Time `gorm:"-"`
 	Deleted      bool      `gorm:"-"`
 }
 
 // SortByPosition sort by JobQueueItem position
-type SortByPosition []JobQueueItem
+type SortByPosition []*JobQueueItem
 
 func (a SortByPosition) Len() int           { return len(a) }
 func (a SortByPosition) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
@@ -0,0 +1,390 @@
+package services
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
+	"github.com/RemoteBuild/Remotebuild/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Create a queue using a temporary sqlite db
+func newTestQueue(t *testing.T, maxParallel int) *JobQueue {
+	dir, err := ioutil.TempDir("", "jobqueue_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sqlite can't handle concurrent writes
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+
+	err = db.AutoMigrate(&models.BuildJob{}, &models.UploadJob{}, &models.Job{}, &models.Batch{}, &models.JobDependency{}, &models.JobAttempt{}, &models.JobTransition{}, &JobQueueItem{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &models.Config{}
+	config.Server.Jobs.MaxParallel = maxParallel
+	config.Server.KeepBuildFiles = true
+
+	queue := NewJobQueue(db, config, nil)
+	t.Cleanup(queue.stop)
+
+	return queue
+}
+
+// Create a job and add it to the queue
+func addTestJob(t *testing.T, queue *JobQueue, priority models.JobPriority, dependsOn ...uint) *JobQueueItem {
+	item, err := newTestJob(queue, priority, dependsOn...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return item
+}
+
+func newTestJob(queue *JobQueue, priority models.JobPriority, dependsOn ...uint) (*JobQueueItem, error) {
+	buildJob := &models.BuildJob{State: libremotebuild.JobWaiting}
+	uploadJob := &models.UploadJob{State: libremotebuild.JobWaiting}
+	if err := queue.db.Create(buildJob).Error; err != nil {
+		return nil, err
+	}
+	if err := queue.db.Create(uploadJob).Error; err != nil {
+		return nil, err
+	}
+
+	job := &models.Job{BuildJobID: buildJob.ID, UploadJobID: uploadJob.ID}
+	if err := queue.db.Create(job).Error; err != nil {
+		return nil, err
+	}
+	job.BuildJob = buildJob
+	job.UploadJob = uploadJob
+
+	if err := models.SaveDependencies(queue.db, job.ID, dependsOn); err != nil {
+		return nil, err
+	}
+	if err := job.Init(queue.db, queue.config); err != nil {
+		return nil, err
+	}
+
+	return queue.AddJob(job, priority)
+}
+
+// Wait until the queue is empty
+func waitEmpty(t *testing.T, queue *JobQueue) {
+	deadline := time.Now().Add(10 * time.Second)
+	for len(queue.GetJobs()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Queue not empty: %d jobs left", len(queue.GetJobs()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJobQueueDispatchOrder(t *testing.T) {
+	queue := newTestQueue(t, 1)
+
+	ran := make(chan uint, 10)
+	queue.runJob = func(item *JobQueueItem) {
+		ran <- item.JobID
+	}
+
+	low := addTestJob(t, queue, models.PriorityLow)
+	normal := addTestJob(t, queue, models.PriorityNormal)
+	urgent := addTestJob(t, queue, models.PriorityUrgent)
+
+	queue.Start()
+
+	for _, expected := range []uint{urgent.JobID, normal.JobID, low.JobID} {
+		select {
+		case id := <-ran:
+			if id != expected {
+				t.Fatalf("Expected job %d to run. Got %d", expected, id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Job didn't run")
+		}
+	}
+}
+
+func TestJobQueuePosition(t *testing.T) {
+	queue := newTestQueue(t, 1)
+
+	low := addTestJob(t, queue, models.PriorityLow)
+	normal := addTestJob(t, queue, models.PriorityNormal)
+	urgent := addTestJob(t, queue, models.PriorityUrgent)
+
+	for i, item := range queue.GetJobs() {
+		pos := queue.GetJobQueuePos(item)
+		if pos != int(item.Position) || pos != i+1 {
+			t.Errorf("Job %d: expected position %d. Got %d, stored %d", item.JobID, i+1, pos, item.Position)
+		}
+	}
+
+	for expected, item := range []*JobQueueItem{urgent, normal, low} {
+		if pos := queue.GetJobQueuePos(item); pos != expected+1 {
+			t.Errorf("Expected job %d at position %d. Got %d", item.JobID, expected+1, pos)
+		}
+	}
+
+	queue.RemoveJob(normal.JobID)
+	if pos := queue.GetJobQueuePos(normal); pos != -1 {
+		t.Errorf("Expected removed job at position -1. Got %d", pos)
+	}
+}
+
+func TestJobQueueWakeOnAdd(t *testing.T) {
+	queue := newTestQueue(t, 1)
+
+	ran := make(chan uint, 1)
+	queue.runJob = func(item *JobQueueItem) {
+		ran <- item.JobID
+	}
+	queue.Start()
+
+	// Let the dispatcher wait for jobs
+	time.Sleep(50 * time.Millisecond)
+
+	item := addTestJob(t, queue, models.PriorityNormal)
+
+	select {
+	case id := <-ran:
+		if id != item.JobID {
+			t.Fatalf("Expected job %d to run. Got %d", item.JobID, id)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("Adding a job didn't wake the dispatcher")
+	}
+}
+
+func TestJobQueueDependencies(t *testing.T) {
+	queue := newTestQueue(t, 2)
+
+	ran := make(chan uint, 2)
+	queue.runJob = func(item *JobQueueItem) {
+		item.Job.SetState(libremotebuild.JobDone)
+		if err := item.Job.Save(); err != nil {
+			t.Error(err)
+		}
+
+		ran <- item.JobID
+	}
+
+	first := addTestJob(t, queue, models.PriorityNormal)
+	second := addTestJob(t, queue, models.PriorityUrgent, first.JobID)
+
+	queue.Start()
+
+	for _, expected := range []uint{first.JobID, second.JobID} {
+		select {
+		case id := <-ran:
+			if id != expected {
+				t.Fatalf("Expected job %d to run. Got %d", expected, id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Job didn't run")
+		}
+	}
+}
+
+func TestJobQueueConcurrent(t *testing.T) {
+	const maxParallel = 3
+	const jobsPerWorker = 10
+	const workers = 4
+
+	queue := newTestQueue(t, maxParallel)
+
+	var active, maxActive int32
+	var mx sync.Mutex
+	runCount := make(map[uint]int)
+
+	queue.runJob = func(item *JobQueueItem) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxActive)
+			if n <= max || atomic.CompareAndSwapInt32(&maxActive, max, n) {
+				break
+			}
+		}
+
+		mx.Lock()
+		runCount[item.JobID]++
+		mx.Unlock()
+
+		time.Sleep(time.Millisecond)
+	}
+
+	queue.Start()
+
+	// Add, inspect, move and cancel jobs while the queue dispatches them
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < jobsPerWorker; i++ {
+				item, err := newTestJob(queue, models.JobPriority(i%3-1))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				queue.FindJob(item.JobID)
+				queue.GetJobQueuePos(item)
+				queue.GetJobs()
+				queue.IsRunning(item.JobID)
+				queue.GetRunningJobs()
+
+				if err := queue.MoveJob(item.JobID, models.MoveFront, 0); err != nil &&
+					err != ErrJobNotInQueue && err != ErrJobIsRunning {
+					t.Error(err)
+				}
+
+				if i%4 == 0 {
+					if err := queue.CancelJob(item.JobID); err != nil && err != ErrJobNotInQueue {
+						t.Error(err)
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	waitEmpty(t, queue)
+
+	if maxActive > maxParallel {
+		t.Errorf("%d jobs ran in parallel. Max: %d", maxActive, maxParallel)
+	}
+
+	mx.Lock()
+	defer mx.Unlock()
+
+	for id, count := range runCount {
+		if count > 1 {
+			t.Errorf("Job %d ran %d times", id, count)
+		}
+	}
+
+	// Only cancelled jobs may be skipped
+	if min := workers * jobsPerWorker * 3 / 4; len(runCount) < min {
+		t.Errorf("Expected at least %d jobs to run. Got %d", min, len(runCount))
+	}
+}
+
+func TestJobQueueAddBatch(t *testing.T) {
+	queue := newTestQueue(t, 1)
+
+	options := JobOptions{
+		Type:  libremotebuild.JobAUR,
+		Image: "test",
+		Args:  map[string]string{libremotebuild.AURPackage: "yay"},
+	}
+
+	items, err := queue.AddBatch(queue.db, []JobOptions{options, options})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items {
+		defer os.RemoveAll(item.Job.DataDir)
+	}
+
+	if len(items) != 2 || items[0].Job.BatchID == 0 || items[0].Job.BatchID != items[1].Job.BatchID {
+		t.Fatalf("Expected 2 jobs with a shared batch. Got %d", len(items))
+	}
+
+	jobs, err := models.GetBatchJobs(queue.db, items[0].Job.BatchID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 {
+		t.Errorf("Expected 2 jobs in batch. Got %d", len(jobs))
+	}
+
+	// A single invalid job rolls back the whole batch
+	invalid := options
+	invalid.DependsOn = []uint{1000}
+
+	if _, err = queue.AddBatch(queue.db, []JobOptions{options, invalid}); err != models.ErrDependencyNotFound {
+		t.Fatalf("Expected ErrDependencyNotFound. Got %v", err)
+	}
+
+	var count int64
+	queue.db.Model(&models.Job{}).Count(&count)
+	if count != 2 {
+		t.Errorf("Expected the batch to be rolled back. Found %d jobs", count)
+	}
+
+	if n := len(queue.GetJobs()); n != 2 {
+		t.Errorf("Expected 2 queued jobs. Got %d", n)
+	}
+}
+
+func TestJobQueueDependencyOwner(t *testing.T) {
+	queue := newTestQueue(t, 1)
+
+	options := JobOptions{
+		Type:   libremotebuild.JobAUR,
+		Image:  "test",
+		Args:   map[string]string{libremotebuild.AURPackage: "yay"},
+		UserID: 1,
+	}
+
+	owned, err := queue.AddNewJob(queue.db, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(owned.Job.DataDir)
+
+	// Other users can't depend on the job
+	other := options
+	other.UserID = 2
+	other.DependsOn = []uint{owned.JobID}
+	if _, err = queue.AddNewJob(queue.db, other); err != models.ErrDependencyNotFound {
+		t.Fatalf("Expected ErrDependencyNotFound. Got %v", err)
+	}
+
+	// Admins can depend on all jobs
+	other.DependOnAnyJob = true
+	item, err := queue.AddNewJob(queue.db, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(item.Job.DataDir)
+
+	// The owner can depend on the job
+	mine := options
+	mine.DependsOn = []uint{owned.JobID}
+	item, err = queue.AddNewJob(queue.db, mine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(item.Job.DataDir)
+}
@@ -1,7 +1,11 @@
 package services
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
 	"github.com/RemoteBuild/Remotebuild/models"
@@ -63,11 +67,40 @@ func (js *JobService) Stop() {
 
 }
 
-// GetOldJobs return n(limit) old jobs
-func (js *JobService) GetOldJobs(limit int) ([]models.Job, error) {
+// Drain stop accepting new jobs and let running jobs finish
+func (js *JobService) Drain() {
+	js.Queue.Drain()
+}
+
+// Draining returns a chan which gets closed if the service starts draining
+func (js *JobService) Draining() <-chan struct{} {
+	return js.Queue.draining
+}
+
+// AwaitDrained waits until all running jobs are done or the
+// drain timeout exceeded. Remaining jobs get cancelled
+func (js *JobService) AwaitDrained() {
+	timeout := js.config.Server.Jobs.DrainTimeout
+	log.Infof("Waiting up to %s for running jobs", timeout)
+
+	if !js.Queue.AwaitRunning(timeout) {
+		log.Warn("Running jobs didn't finish in time. Cancelling them")
+	}
+
+	js.Stop()
+}
+
+// GetOldJobs return n(limit) old jobs. If userID is
+// not 0, only jobs of the user are returned
+func (js *JobService) GetOldJobs(limit int, userID uint) ([]models.Job, error) {
 	var jobs []models.Job
 
-	if err := js.Debug().Model(&models.Job{}).
+	query := js.Model(&models.Job{})
+	if userID != 0 {
+		query = query.Where("jobs.user_id = ?", userID)
+	}
+
+	if err := query.Debug().
 		Joins("left join build_jobs on build_jobs.id = jobs.build_job_id").
 		Joins("left join upload_jobs on upload_jobs.id = jobs.upload_job_id").
 		Preload("BuildJob").
@@ -84,19 +117,27 @@ func (js *JobService) GetOldJobs(limit int) ([]models.Job, error) {
 
 // GetJobInfo returns informations about a job
 func (js *JobService) GetJobInfo(jobID uint) (*models.Job, error) {
+	return models.LoadJob(js.DB, jobID)
+}
+
+// GetJobOwner returns the ID of the user who created
+// the job. Returns false if the job doesn't exist
+func (js *JobService) GetJobOwner(jobID uint) (uint, bool, error) {
+	if item := js.Queue.FindJob(jobID); item != nil {
+		return item.Job.UserID, true, nil
+	}
+
 	var job models.Job
+	err := js.Select("id", "user_id").Where("id=?", jobID).First(&job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, false, nil
+		}
 
-	// Load unfinished jobs
-	err := js.Model(&models.Job{}).
-		Preload("BuildJob").
-		Preload("UploadJob").
-		Where("id=?", jobID).
-		First(&job).Error
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, err
+		return 0, false, err
 	}
 
-	return &job, nil
+	return job.UserID, true, nil
 }
 
 // GetOldLogs get old logs for job
@@ -111,3 +152,135 @@ func (js *JobService) GetOldLogs(jobID uint) (string, error) {
 
 	return job.LastLogs, nil
 }
+
+// RerunJob creates a new job owned by userID with the settings of an
+// existing job. Secret args of the job have to be passed again in the request
+func (js *JobService) RerunJob(request models.RerunJobRequest, priority models.JobPriority, userID uint) (*JobQueueItem, error) {
+	job, err := js.GetJobInfo(request.JobID)
+	if err != nil || job == nil {
+		return nil, err
+	}
+
+	// Jobs created before args were kept can't be rerun
+	if len(job.Argdata) == 0 {
+		return nil, models.ErrNoArgsStored
+	}
+
+	args, err := job.GetArgs()
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply overrides
+	for k, v := range request.Args {
+		args[k] = v
+	}
+
+	// Secrets aren't stored after a job is done
+	if missing := models.MissingArgs(args, job.GetSecretArgNames()); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", models.ErrMissingSecretArgs, strings.Join(missing, ", "))
+	}
+
+	uploadType := job.UploadJob.Type
+	if request.UploadType != nil {
+		uploadType = *request.UploadType
+	}
+
+	timeout := job.BuildJob.Timeout
+	if request.Timeout > 0 {
+		timeout = time.Duration(request.Timeout)
+	}
+
+	// Keep the limits of the job
+	var limits models.ResourceLimits
+	if len(job.BuildJob.LimitData) > 0 {
+		if err = json.Unmarshal([]byte(job.BuildJob.LimitData), &limits); err != nil {
+			return nil, err
+		}
+	}
+
+	var retry *models.RetryPolicy
+	if job.MaxAttempts > 0 {
+		policy := job.GetRetryPolicy()
+		retry = &policy
+	}
+
+	return js.Queue.AddNewJob(js.DB, JobOptions{
+		Type:       job.BuildJob.Type,
+		UploadType: uploadType,
+		Args:       args,
+		UseCcache:  job.BuildJob.UseCcache,
+		Priority:   priority,
+		Retry:      retry,
+		Timeout:    timeout,
+		Limits:     limits,
+		Image:      job.BuildJob.Image,
+		Force:      request.Force,
+		UserID:     userID,
+	})
+}
+
+// GetBatch returns a batch and its jobs. Returns nil if not found
+func (js *JobService) GetBatch(batchID uint) (*models.Batch, []models.Job, error) {
+	var batch models.Batch
+	if err := js.First(&batch, batchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil
+		}
+
+		return nil, nil, err
+	}
+
+	jobs, err := models.GetBatchJobs(js.DB, batch.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &batch, jobs, nil
+}
+
+// GetBatches returns the latest n(limit) batches. If
+// userID is not 0, only batches of the user are returned
+func (js *JobService) GetBatches(limit int, userID uint) ([]models.Batch, error) {
+	var batches []models.Batch
+
+	query := js.Model(&models.Batch{})
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	err := query.
+		Order("id DESC").
+		Limit(limit).
+		Find(&batches).Error
+
+	return batches, err
+}
+
+// CancelBatch cancels all queued jobs of a batch, starting
+// with the last one. Returns the count of cancelled jobs
+func (js *JobService) CancelBatch(batchID uint) (int, error) {
+	var jobIDs []uint
+	err := js.Model(&models.Job{}).
+		Where("batch_id=?", batchID).
+		Order("id DESC").
+		Pluck("id", &jobIDs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	cancelled := 0
+	for _, id := range jobIDs {
+		err := js.Queue.CancelJob(id)
+		if err == ErrJobNotInQueue {
+			continue
+		}
+		if err != nil {
+			return cancelled, err
+		}
+
+		cancelled++
+	}
+
+	return cancelled, nil
+}
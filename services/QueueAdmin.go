@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+
+	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
+	"github.com/RemoteBuild/Remotebuild/models"
+	"gorm.io/gorm"
+)
+
+// The functions in this file work on the stored queue without a
+// running JobQueue. A running server doesn't notice their changes
+
+var (
+	// ErrJobNotFound if a job doesn't exist
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobFinished if a job isn't queued or running anymore
+	ErrJobFinished = errors.New("job is finished already")
+)
+
+// GetQueueItems returns the stored queue sorted by position
+func GetQueueItems(db *gorm.DB) ([]*JobQueueItem, error) {
+	var items []*JobQueueItem
+
+	err := db.Model(&JobQueueItem{}).
+		Preload("Job").
+		Preload("Job.BuildJob").
+		Preload("Job.UploadJob").
+		Order("position").
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip items of deleted jobs
+	valid := items[:0]
+	for _, item := range items {
+		if item.Job != nil && item.Job.BuildJob != nil && item.Job.UploadJob != nil {
+			valid = append(valid, item)
+		}
+	}
+
+	return valid, nil
+}
+
+// AbortJob marks a queued or running job as cancelled, or as failed if
+// fail is set, and removes it from the stored queue. A restarted
+// server won't pick up the job again
+func AbortJob(db *gorm.DB, jobID uint, fail bool, reason string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		job, err := models.LoadJob(tx, jobID)
+		if err != nil {
+			return err
+		}
+		if job == nil || job.BuildJob == nil || job.UploadJob == nil {
+			return ErrJobNotFound
+		}
+
+		var queued int64
+		if err = tx.Model(&JobQueueItem{}).Where("job_id = ?", jobID).Count(&queued).Error; err != nil {
+			return err
+		}
+
+		if queued == 0 && isFinalState(job.GetState()) {
+			return ErrJobFinished
+		}
+
+		return abortJob(tx, job, fail, reason)
+	})
+}
+
+// PurgeQueue removes all jobs from the stored queue. Running jobs are
+// marked as failed, waiting jobs as cancelled. Returns the count of
+// removed jobs
+func PurgeQueue(db *gorm.DB, reason string) (int, error) {
+	var purged int
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		items, err := GetQueueItems(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			item.Job.DB = tx
+			fail := item.Job.GetState() == libremotebuild.JobRunning || item.Job.GetState() == libremotebuild.JobPaused
+
+			if err = abortJob(tx, item.Job, fail, reason); err != nil {
+				return err
+			}
+			purged++
+		}
+
+		// Remove items of deleted jobs as well
+		return tx.Where("1 = 1").Delete(&JobQueueItem{}).Error
+	})
+
+	return purged, err
+}
+
+// Update the state of a job and remove it from the stored queue
+func abortJob(tx *gorm.DB, job *models.Job, fail bool, reason string) error {
+	state, stage := libremotebuild.JobCancelled, models.StageCancelled
+	if fail {
+		state, stage = libremotebuild.JobFailed, models.StageFailed
+	}
+
+	job.DB = tx
+	job.SetState(state)
+	job.Result = reason
+
+	// Secrets are only kept until a job is done
+	job.SecretArgdata = ""
+
+	if err := job.Save(); err != nil {
+		return err
+	}
+
+	if err := models.SaveTransition(tx, job.ID, stage, reason); err != nil {
+		return err
+	}
+
+	return tx.Where("job_id = ?", job.ID).Delete(&JobQueueItem{}).Error
+}
+
+// Return true if a job with the state can't change anymore
+func isFinalState(state libremotebuild.JobState) bool {
+	switch state {
+	case libremotebuild.JobDone, libremotebuild.JobCancelled, libremotebuild.JobFailed, models.JobTimedOut:
+		return true
+	}
+
+	return false
+}
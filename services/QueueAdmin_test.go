@@ -0,0 +1,86 @@
+package services
+
+import (
+	"testing"
+
+	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
+	"github.com/RemoteBuild/Remotebuild/models"
+)
+
+func TestAbortJob(t *testing.T) {
+	queue := newTestQueue(t, 1)
+
+	item := addTestJob(t, queue, models.PriorityNormal)
+
+	if err := AbortJob(queue.db, item.JobID, false, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := models.LoadJob(queue.db, item.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.GetState() != libremotebuild.JobCancelled || job.Result != "test" {
+		t.Errorf("Expected cancelled job. Got %s: %s", job.GetState(), job.Result)
+	}
+	if n := len(job.Transitions); n == 0 || job.Transitions[n-1].Stage != models.StageCancelled {
+		t.Error("Expected cancel to be recorded")
+	}
+
+	items, err := GetQueueItems(queue.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Errorf("Expected empty stored queue. Got %d items", len(items))
+	}
+
+	if err = AbortJob(queue.db, item.JobID, false, "test"); err != ErrJobFinished {
+		t.Errorf("Expected ErrJobFinished. Got %v", err)
+	}
+	if err = AbortJob(queue.db, 1000, false, "test"); err != ErrJobNotFound {
+		t.Errorf("Expected ErrJobNotFound. Got %v", err)
+	}
+}
+
+func TestPurgeQueue(t *testing.T) {
+	queue := newTestQueue(t, 1)
+
+	waiting := addTestJob(t, queue, models.PriorityNormal)
+	running := addTestJob(t, queue, models.PriorityNormal)
+
+	running.Job.SetState(libremotebuild.JobRunning)
+	if err := running.Job.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := PurgeQueue(queue.db, "purged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 2 {
+		t.Errorf("Expected 2 purged jobs. Got %d", purged)
+	}
+
+	for id, expected := range map[uint]libremotebuild.JobState{
+		waiting.JobID: libremotebuild.JobCancelled,
+		running.JobID: libremotebuild.JobFailed,
+	} {
+		job, err := models.LoadJob(queue.db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if job.GetState() != expected {
+			t.Errorf("Job %d: expected %s. Got %s", id, expected, job.GetState())
+		}
+	}
+
+	// A restarted queue must not pick up purged jobs
+	if err = queue.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(queue.GetJobs()); n != 0 {
+		t.Errorf("Expected no jobs after reload. Got %d", n)
+	}
+}
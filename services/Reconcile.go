@@ -0,0 +1,122 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/RemoteBuild/Remotebuild/models"
+	docker "github.com/fsouza/go-dockerclient"
+	log "github.com/sirupsen/logrus"
+)
+
+// reconcile build containers and data dirs left by a previous
+// server run. building contains the items by JobID which were
+// building before the server stopped. Reattached items are moved
+// to the front of the queue
+func (jq *JobQueue) reconcile(building map[uint]*JobQueueItem) {
+	if err := jq.reconcileContainers(building); err != nil {
+		log.Error("Reconciling build containers failed: ", err)
+	}
+
+	jq.removeOrphanedDataDirs()
+}
+
+// Reattach to or remove labelled build containers
+func (jq *JobQueue) reconcileContainers(building map[uint]*JobQueueItem) error {
+	client, err := docker.NewClientFromEnv()
+	if err != nil {
+		return err
+	}
+
+	containers, err := client.ListContainers(docker.ListContainersOptions{
+		All: true,
+		Filters: map[string][]string{
+			"label": {models.ContainerLabelJob},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	var reattached []*JobQueueItem
+	isReattached := make(map[*JobQueueItem]bool)
+
+	for _, container := range containers {
+		jobID, _ := strconv.ParseUint(container.Labels[models.ContainerLabelJob], 10, 32)
+		item, ok := building[uint(jobID)]
+
+		// Reattach to containers of unfinished builds
+		if ok && !isReattached[item] && jq.config.Server.Jobs.OnRestart == models.RestartReattach {
+			log.Infof("Reattaching job %d to container %s", item.JobID, container.ID)
+			item.Job.BuildJob.Reattach(container.ID)
+			reattached = append(reattached, item)
+			isReattached[item] = true
+			continue
+		}
+
+		log.Infof("Removing build container %s of job %d", container.ID, jobID)
+		if container.State == "running" {
+			if err := client.StopContainer(container.ID, 1); err != nil {
+				log.Warn(err)
+			}
+		}
+
+		if jq.config.Server.KeepBuildContainer {
+			continue
+		}
+
+		err := client.RemoveContainer(docker.RemoveContainerOptions{
+			ID:    container.ID,
+			Force: true,
+		})
+		if err != nil {
+			log.Warn(err)
+		}
+	}
+
+	if len(reattached) == 0 {
+		return nil
+	}
+
+	// Run reattached jobs first
+	jq.sortPosition()
+	jobs := append([]*JobQueueItem{}, reattached...)
+	for _, item := range jq.jobs {
+		if !isReattached[item] {
+			jobs = append(jobs, item)
+		}
+	}
+	jq.jobs = jobs
+
+	return jq.savePositions()
+}
+
+// Remove data dirs which don't belong to a queued job
+func (jq *JobQueue) removeOrphanedDataDirs() {
+	if jq.config.Server.KeepBuildFiles {
+		return
+	}
+
+	dirs, err := filepath.Glob(filepath.Join(os.TempDir(), "remotebuild_*"))
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	used := make(map[string]bool)
+	for _, item := range jq.jobs {
+		used[filepath.Clean(item.Job.DataDir)] = true
+	}
+
+	for _, dir := range dirs {
+		if used[filepath.Clean(dir)] {
+			continue
+		}
+
+		log.Info("Removing orphaned data dir ", dir)
+		if err := os.RemoveAll(dir); err != nil {
+			log.Warn(err)
+		}
+	}
+}
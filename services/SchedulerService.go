@@ -0,0 +1,140 @@
+package services
+
+import (
+	"time"
+
+	"github.com/RemoteBuild/Remotebuild/models"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// SchedulerService enqueues jobs of due schedules
+type SchedulerService struct {
+	db     *gorm.DB
+	config *models.Config
+	queue  *JobQueue
+}
+
+// NewSchedulerService create a new schedulerservice
+func NewSchedulerService(config *models.Config, db *gorm.DB, queue *JobQueue) *SchedulerService {
+	return &SchedulerService{
+		config: config,
+		db:     db,
+		queue:  queue,
+	}
+}
+
+// Start starts the service
+func (ss *SchedulerService) Start() {
+	go ss.run()
+}
+
+func (ss *SchedulerService) run() {
+	for {
+		ss.runDueSchedules()
+		time.Sleep(30 * time.Second)
+	}
+}
+
+// Enqueue a job for each due schedule
+func (ss *SchedulerService) runDueSchedules() {
+	// Keep schedules due until the next start
+	if ss.queue.IsDraining() {
+		return
+	}
+
+	var schedules []models.Schedule
+
+	now := time.Now()
+
+	err := ss.db.Model(&models.Schedule{}).
+		Where("paused = ?", false).
+		Where("next_run <= ?", now).
+		Find(&schedules).Error
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	for i := range schedules {
+		ss.runSchedule(&schedules[i], now)
+	}
+}
+
+// Enqueue a job for schedule and calculate its next run
+func (ss *SchedulerService) runSchedule(schedule *models.Schedule, now time.Time) {
+	args, err := schedule.GetArgs()
+	if err != nil {
+		log.Errorf("Can't get args of schedule %d: %s", schedule.ID, err)
+	} else {
+		jqi, err := ss.queue.AddNewJob(ss.db, JobOptions{
+			Type:       schedule.BuildType,
+			UploadType: schedule.UploadType,
+			Args:       args,
+			UseCcache:  !schedule.DisableCcache,
+			Priority:   schedule.Priority,
+			UserID:     schedule.UserID,
+		})
+
+		if err != nil {
+			log.Errorf("Can't run schedule %d: %s", schedule.ID, err)
+		} else {
+			log.Infof("Schedule %d created job %d", schedule.ID, jqi.JobID)
+		}
+	}
+
+	schedule.LastRun = now
+	if err = schedule.UpdateNextRun(now); err != nil {
+		// Don't try to run invalid schedules again
+		log.Errorf("Pausing schedule %d: %s", schedule.ID, err)
+		schedule.Paused = true
+	}
+
+	if err = ss.db.Save(schedule).Error; err != nil {
+		log.Error(err)
+	}
+}
+
+// GetSchedules returns all schedules of a user
+func (ss *SchedulerService) GetSchedules(userID uint) ([]models.Schedule, error) {
+	var schedules []models.Schedule
+
+	err := ss.db.Model(&models.Schedule{}).
+		Where("user_id=?", userID).
+		Order("id").
+		Find(&schedules).Error
+
+	return schedules, err
+}
+
+// GetSchedule returns the schedule with the given ID owned by the user
+func (ss *SchedulerService) GetSchedule(userID, scheduleID uint) (*models.Schedule, error) {
+	var schedule models.Schedule
+
+	err := ss.db.Model(&models.Schedule{}).
+		Where("user_id=? AND id=?", userID, scheduleID).
+		First(&schedule).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+// SetPaused pauses or resumes a schedule
+func (ss *SchedulerService) SetPaused(schedule *models.Schedule, paused bool) error {
+	// Don't run missed activations after resuming
+	if !paused && schedule.Paused {
+		if err := schedule.UpdateNextRun(time.Now()); err != nil {
+			return err
+		}
+	}
+
+	schedule.Paused = paused
+	return ss.db.Save(schedule).Error
+}
+
+// DeleteSchedule deletes a schedule
+func (ss *SchedulerService) DeleteSchedule(schedule *models.Schedule) error {
+	return ss.db.Delete(schedule).Error
+}
@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/RemoteBuild/Remotebuild/models"
+	"github.com/RemoteBuild/Remotebuild/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ArchiveFormat version of the archive format
+const ArchiveFormat = 1
+
+// Errors of archives
+var (
+	ErrArchiveFormat    = errors.New("unsupported archive format")
+	ErrArchiveTooNew    = errors.New("archive was created by a newer version")
+	ErrSchemaNotLatest  = errors.New("database has pending migrations")
+	ErrDatabaseNotEmpty = errors.New("database is not empty")
+)
+
+// Archive a portable copy of the database. Artifacts
+// aren't included, only their metadata. Contains password
+// hashes and, if exported with secrets, the secret args
+// of unfinished jobs and schedules
+type Archive struct {
+	Format        int       `json:"format"`
+	SchemaVersion uint      `json:"schemaVersion"`
+	Created       time.Time `json:"created"`
+	Secrets       bool      `json:"secrets"` // Secret job and schedule args are included
+
+	Users     []models.User         `json:"users"`
+	APITokens []models.APIToken     `json:"apiTokens"`
+	Sessions  []models.LoginSession `json:"sessions,omitempty"`
+
+	BuildJobs    []models.BuildJob       `json:"buildJobs"`
+	UploadJobs   []models.UploadJob      `json:"uploadJobs"`
+	Jobs         []models.Job            `json:"jobs"`
+	Batches      []models.Batch          `json:"batches"`
+	Dependencies []models.JobDependency  `json:"dependencies"`
+	Attempts     []models.JobAttempt     `json:"attempts"`
+	Transitions  []models.JobTransition  `json:"transitions"`
+	Queue        []services.JobQueueItem `json:"queue"`
+	Schedules    []models.Schedule       `json:"schedules"`
+	Artifacts    []ArtifactInfo          `json:"artifacts"`
+}
+
+// ArtifactInfo metadata of a stored build
+type ArtifactInfo struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	IsDir   bool      `json:"isDir"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// ExportOptions options for exporting the database
+type ExportOptions struct {
+	Sessions         bool   // Include login sessions
+	Secrets          bool   // Include secret args of unfinished jobs and schedules
+	LocalStoragePath string // Dir of the stored builds. Empty to skip artifacts
+}
+
+// Tables of the archive in the order they have to be imported
+func (archive *Archive) tables() []interface{} {
+	return []interface{}{
+		&archive.Users,
+		&archive.APITokens,
+		&archive.Sessions,
+		&archive.BuildJobs,
+		&archive.UploadJobs,
+		&archive.Batches,
+		&archive.Jobs,
+		&archive.Dependencies,
+		&archive.Attempts,
+		&archive.Transitions,
+		&archive.Queue,
+		&archive.Schedules,
+	}
+}
+
+// Export writes a gzip compressed archive of the database to w
+func Export(db *gorm.DB, w io.Writer, options ExportOptions) (*Archive, error) {
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if version != LatestVersion() {
+		return nil, fmt.Errorf("%w: version %d, expected %d", ErrSchemaNotLatest, version, LatestVersion())
+	}
+
+	archive := &Archive{
+		Format:        ArchiveFormat,
+		SchemaVersion: version,
+		Created:       time.Now(),
+		Secrets:       options.Secrets,
+	}
+
+	for _, table := range archive.tables() {
+		if table == &archive.Sessions && !options.Sessions {
+			continue
+		}
+
+		// Keep soft deleted rows to create an exact copy
+		if err = db.Unscoped().Order("id").Find(table).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// Secret args are stored in plain text until a job
+	// is done and as long as a schedule exists
+	if !options.Secrets {
+		for i := range archive.Jobs {
+			archive.Jobs[i].SecretArgdata = ""
+		}
+		for i := range archive.Schedules {
+			archive.Schedules[i].SecretArgdata = ""
+		}
+	}
+
+	if len(options.LocalStoragePath) > 0 {
+		if archive.Artifacts, err = listArtifacts(options.LocalStoragePath); err != nil {
+			return nil, err
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	if err = json.NewEncoder(gz).Encode(archive); err != nil {
+		return nil, err
+	}
+
+	return archive, gz.Close()
+}
+
+// Import reads an archive created by Export into an empty database
+func Import(db *gorm.DB, r io.Reader) (*Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var archive Archive
+	if err = json.NewDecoder(gz).Decode(&archive); err != nil {
+		return nil, err
+	}
+
+	if archive.Format != ArchiveFormat {
+		return nil, fmt.Errorf("%w: %d", ErrArchiveFormat, archive.Format)
+	}
+	if archive.SchemaVersion > LatestVersion() {
+		return nil, fmt.Errorf("%w: schema version %d", ErrArchiveTooNew, archive.SchemaVersion)
+	}
+
+	if _, err = Migrate(db); err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := checkEmpty(tx); err != nil {
+			return err
+		}
+
+		for _, table := range archive.tables() {
+			if err := insertAll(tx, table); err != nil {
+				return err
+			}
+		}
+
+		return resetSequences(tx, archive.tables())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &archive, nil
+}
+
+// MissingArtifacts returns the artifacts of the archive which
+// don't exist in localStoragePath
+func (archive *Archive) MissingArtifacts(localStoragePath string) []ArtifactInfo {
+	var missing []ArtifactInfo
+	for _, artifact := range archive.Artifacts {
+		if _, err := os.Stat(filepath.Join(localStoragePath, artifact.Name)); err != nil {
+			missing = append(missing, artifact)
+		}
+	}
+
+	return missing
+}
+
+// JobsWithoutSecrets returns the IDs of queued jobs which
+// lost their secret args because they weren't exported
+func (archive *Archive) JobsWithoutSecrets() []uint {
+	if archive.Secrets {
+		return nil
+	}
+
+	queued := make(map[uint]bool, len(archive.Queue))
+	for _, item := range archive.Queue {
+		queued[item.JobID] = true
+	}
+
+	var ids []uint
+	for _, job := range archive.Jobs {
+		if queued[job.ID] && len(job.SecretArgs) > 0 {
+			ids = append(ids, job.ID)
+		}
+	}
+
+	return ids
+}
+
+// SchedulesWithoutSecrets returns the IDs of schedules which
+// lost their secret args because they weren't exported
+func (archive *Archive) SchedulesWithoutSecrets() []uint {
+	if archive.Secrets {
+		return nil
+	}
+
+	var ids []uint
+	for _, schedule := range archive.Schedules {
+		if !schedule.DeletedAt.Valid && len(schedule.SecretArgs) > 0 {
+			ids = append(ids, schedule.ID)
+		}
+	}
+
+	return ids
+}
+
+// Return an error if the db contains users or jobs
+func checkEmpty(db *gorm.DB) error {
+	for _, model := range []interface{}{&models.User{}, &models.Job{}} {
+		var count int64
+		if err := db.Unscoped().Model(model).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return ErrDatabaseNotEmpty
+		}
+	}
+
+	return nil
+}
+
+// Insert all rows of a slice pointer keeping their IDs
+func insertAll(tx *gorm.DB, table interface{}) error {
+	rows := reflect.ValueOf(table).Elem()
+	for i := 0; i < rows.Len(); i++ {
+		if err := tx.Omit(clause.Associations).Create(rows.Index(i).Addr().Interface()).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Postgres doesn't advance sequences for inserts with IDs
+func resetSequences(tx *gorm.DB, tables []interface{}) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+
+	for _, rows := range tables {
+		table, err := tableName(tx, rows)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', 'id'), COALESCE((SELECT MAX(id) FROM %s), 0) + 1, false)", table, table)).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// List the stored builds in localStoragePath
+func listArtifacts(localStoragePath string) ([]ArtifactInfo, error) {
+	files, err := ioutil.ReadDir(localStoragePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	artifacts := make([]ArtifactInfo, len(files))
+	for i, file := range files {
+		artifacts[i] = ArtifactInfo{
+			Name:    file.Name(),
+			Size:    file.Size(),
+			IsDir:   file.IsDir(),
+			ModTime: file.ModTime(),
+		}
+	}
+
+	return artifacts, nil
+}
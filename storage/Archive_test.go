@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	libremotebuild "github.com/RemoteBuild/LibRemotebuild"
+	"github.com/RemoteBuild/Remotebuild/models"
+	"github.com/RemoteBuild/Remotebuild/services"
+)
+
+func TestExportImport(t *testing.T) {
+	src := newTestDB(t)
+	if _, err := Migrate(src); err != nil {
+		t.Fatal(err)
+	}
+
+	user := models.User{Username: "bob", Password: "hash", RoleID: models.RoleAdmin}
+	buildJob := models.BuildJob{State: libremotebuild.JobDone}
+	uploadJob := models.UploadJob{State: libremotebuild.JobWaiting}
+	for _, row := range []interface{}{&user, &buildJob, &uploadJob} {
+		if err := src.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	job := models.Job{BuildJobID: buildJob.ID, UploadJobID: uploadJob.ID, UserID: user.ID, Info: "AUR: yay",
+		SecretArgdata: `{"TOKEN":"secret"}`, SecretArgs: "TOKEN"}
+	if err := src.Create(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Create(&services.JobQueueItem{JobID: job.ID, Position: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Create(&models.LoginSession{UserID: user.ID, TokenHash: "hash"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	schedule, err := models.NewSchedule(src, models.Schedule{UserID: user.ID, Cron: "@daily"}, map[string]string{"pkg": "yay", "TOKEN": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := Export(src, &buf, ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newTestDB(t)
+	archive, err := Import(dst, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Sessions) != 0 {
+		t.Error("Sessions must only be exported if requested")
+	}
+	if archive.Jobs[0].SecretArgdata != "" {
+		t.Error("Secret args must only be exported if requested")
+	}
+	if ids := archive.JobsWithoutSecrets(); len(ids) != 1 || ids[0] != job.ID {
+		t.Errorf("Expected job %d without secrets. Got %v", job.ID, ids)
+	}
+	if archive.Schedules[0].SecretArgdata != "" || strings.Contains(archive.Schedules[0].Argdata, "secret") {
+		t.Error("Secret schedule args must only be exported if requested")
+	}
+	if ids := archive.SchedulesWithoutSecrets(); len(ids) != 1 || ids[0] != schedule.ID {
+		t.Errorf("Expected schedule %d without secrets. Got %v", schedule.ID, ids)
+	}
+
+	var importedSchedule models.Schedule
+	if err = dst.First(&importedSchedule, schedule.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err = importedSchedule.GetArgs(); !errors.Is(err, models.ErrMissingSecretArgs) {
+		t.Errorf("Expected ErrMissingSecretArgs. Got %v", err)
+	}
+
+	imported, err := models.LoadJob(dst, job.ID)
+	if err != nil || imported == nil {
+		t.Fatalf("Expected imported job. Got %v", err)
+	}
+	if imported.UserID != user.ID || imported.Info != job.Info || imported.GetState() != libremotebuild.JobWaiting {
+		t.Errorf("Imported job differs: %+v", imported)
+	}
+
+	items, err := services.GetQueueItems(dst)
+	if err != nil || len(items) != 1 || items[0].JobID != job.ID {
+		t.Errorf("Expected imported queue item. Got %d %v", len(items), err)
+	}
+
+	// New rows must not collide with imported IDs
+	next := models.Job{}
+	if err = dst.Create(&next).Error; err != nil || next.ID <= job.ID {
+		t.Errorf("Expected new job ID after %d. Got %d %v", job.ID, next.ID, err)
+	}
+
+	withSecrets, err := Export(src, ioutil.Discard, ExportOptions{Secrets: true})
+	if err != nil || withSecrets.Jobs[0].SecretArgdata != job.SecretArgdata || withSecrets.Schedules[0].SecretArgdata != schedule.SecretArgdata {
+		t.Errorf("Expected exported secret args. Got %v", err)
+	}
+
+	// Importing twice must fail
+	if _, err = Import(dst, bytes.NewReader(buf.Bytes())); err != ErrDatabaseNotEmpty {
+		t.Errorf("Expected ErrDatabaseNotEmpty. Got %v", err)
+	}
+}
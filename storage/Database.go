@@ -5,14 +5,27 @@ import (
 	"strings"
 
 	"github.com/RemoteBuild/Remotebuild/models"
-	"github.com/RemoteBuild/Remotebuild/services"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-//ConnectToDatabase connects to database
+//ConnectToDatabase connects to database and applies pending migrations
 func ConnectToDatabase(config *models.Config) (*gorm.DB, error) {
+	db, err := OpenDatabase(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = Migrate(db); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// OpenDatabase connects to the database without migrating it
+func OpenDatabase(config *models.Config) (*gorm.DB, error) {
 	dbType := strings.ToLower(config.Server.Database.DatabaseType)
 
 	// Use default if notset
@@ -44,16 +57,6 @@ func ConnectToDatabase(config *models.Config) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	// Automigration
-	db.AutoMigrate(
-		&models.LoginSession{},
-		&models.User{},
-		&models.BuildJob{},
-		&models.UploadJob{},
-		&models.Job{},
-		&services.JobQueueItem{},
-	)
-
 	// Don't perform connection tests if sqlite is picked
 	if dbType == "sqlite" {
 		return db, nil
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSchemaTooNew if the db was migrated by a newer version
+	ErrSchemaTooNew = errors.New("database schema is newer than this version supports")
+
+	// ErrIrreversible if a migration can't be rolled back
+	ErrIrreversible = errors.New("migration can't be rolled back")
+)
+
+// Migration a numbered change of the db schema
+type Migration struct {
+	Version     uint
+	Description string
+	Up          func(tx *gorm.DB) error
+	Down        func(tx *gorm.DB) error // nil if the migration can't be rolled back
+}
+
+// SchemaMigration an applied migration
+type SchemaMigration struct {
+	Version     uint `gorm:"primaryKey;autoIncrement:false"`
+	Description string
+	AppliedAt   time.Time
+}
+
+// MigrationStatus state of a migration
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// LatestVersion returns the schema version this binary expects
+func LatestVersion() uint {
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion returns the version of the db schema. 0 if not migrated yet
+func SchemaVersion(db *gorm.DB) (uint, error) {
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return 0, nil
+	}
+
+	var version uint
+	err := db.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// Migrate applies all pending migrations. Returns the count of
+// applied migrations or ErrSchemaTooNew if the db was migrated
+// by a newer version
+func Migrate(db *gorm.DB) (int, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return 0, err
+	}
+
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return 0, err
+	}
+
+	if current > LatestVersion() {
+		return 0, fmt.Errorf("%w: %d > %d", ErrSchemaTooNew, current, LatestVersion())
+	}
+
+	applied := 0
+	for _, migration := range migrations {
+		if migration.Version <= current {
+			continue
+		}
+
+		log.Infof("Applying migration %d: %s", migration.Version, migration.Description)
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+
+			return tx.Create(&SchemaMigration{
+				Version:     migration.Version,
+				Description: migration.Description,
+				AppliedAt:   time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %d failed: %w", migration.Version, err)
+		}
+
+		applied++
+	}
+
+	return applied, nil
+}
+
+// RollbackPlan returns the latest n applied migrations, newest
+// first. Returns ErrIrreversible if one of them can't be rolled
+// back, which includes the initial schema
+func RollbackPlan(db *gorm.DB, n int) ([]Migration, error) {
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if current > LatestVersion() {
+		return nil, fmt.Errorf("%w: %d > %d", ErrSchemaTooNew, current, LatestVersion())
+	}
+
+	var plan []Migration
+	for i := len(migrations) - 1; i >= 0 && len(plan) < n; i-- {
+		migration := migrations[i]
+		if migration.Version > current {
+			continue
+		}
+
+		if migration.Down == nil {
+			return nil, fmt.Errorf("%w: %d %s", ErrIrreversible, migration.Version, migration.Description)
+		}
+
+		plan = append(plan, migration)
+	}
+
+	return plan, nil
+}
+
+// Rollback reverts the latest n applied migrations. Nothing is
+// reverted if one of them can't be rolled back. Returns the
+// count of reverted migrations
+func Rollback(db *gorm.DB, n int) (int, error) {
+	plan, err := RollbackPlan(db, n)
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for _, migration := range plan {
+		log.Infof("Rolling back migration %d: %s", migration.Version, migration.Description)
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("rollback of migration %d failed: %w", migration.Version, err)
+		}
+
+		reverted++
+	}
+
+	return reverted, nil
+}
+
+// GetMigrationStatus returns all known migrations and whether they are applied
+func GetMigrationStatus(db *gorm.DB) ([]MigrationStatus, error) {
+	var applied []SchemaMigration
+	if db.Migrator().HasTable(&SchemaMigration{}) {
+		if err := db.Order("version").Find(&applied).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	appliedAt := make(map[uint]time.Time, len(applied))
+	for _, migration := range applied {
+		appliedAt[migration.Version] = migration.AppliedAt
+	}
+
+	status := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		at, ok := appliedAt[migration.Version]
+		status[i] = MigrationStatus{
+			Migration: migration,
+			Applied:   ok,
+			AppliedAt: at,
+		}
+	}
+
+	return status, nil
+}
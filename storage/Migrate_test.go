@@ -0,0 +1,320 @@
+package storage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RemoteBuild/Remotebuild/models"
+	"github.com/RemoteBuild/Remotebuild/services"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open an empty temporary sqlite db
+func newTestDB(t *testing.T) *gorm.DB {
+	dir, err := ioutil.TempDir("", "migrate_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+
+	return db
+}
+
+func TestMigrate(t *testing.T) {
+	db := newTestDB(t)
+
+	applied, err := Migrate(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("Expected %d applied migrations. Got %d", len(migrations), applied)
+	}
+
+	if version, _ := SchemaVersion(db); version != LatestVersion() {
+		t.Errorf("Expected version %d. Got %d", LatestVersion(), version)
+	}
+
+	// Nothing left to do
+	if applied, err = Migrate(db); err != nil || applied != 0 {
+		t.Errorf("Expected no migrations to apply. Got %d %v", applied, err)
+	}
+
+	if !db.Migrator().HasIndex(&models.Job{}, indexName("jobs", "user_id")) {
+		t.Error("Expected index on jobs.user_id")
+	}
+}
+
+func TestRollback(t *testing.T) {
+	db := newTestDB(t)
+
+	if _, err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	reverted, err := Rollback(db, 1)
+	if err != nil || reverted != 1 {
+		t.Fatalf("Expected one reverted migration. Got %d %v", reverted, err)
+	}
+
+	if version, _ := SchemaVersion(db); version != LatestVersion()-1 {
+		t.Errorf("Expected version %d. Got %d", LatestVersion()-1, version)
+	}
+
+	if db.Migrator().HasColumn(&models.Schedule{}, "secret_args") {
+		t.Error("Expected secret schedule args to be dropped")
+	}
+
+	// Reapply
+	if applied, err := Migrate(db); err != nil || applied != 1 {
+		t.Errorf("Expected one migration to apply. Got %d %v", applied, err)
+	}
+}
+
+func TestRollbackReapply(t *testing.T) {
+	db := newTestDB(t)
+
+	if _, err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Down to the irreversible owner assignment
+	steps := int(LatestVersion() - 12)
+	if reverted, err := Rollback(db, steps); err != nil || reverted != steps {
+		t.Fatalf("Expected %d reverted migrations. Got %d %v", steps, reverted, err)
+	}
+	if db.Migrator().HasTable("api_tokens") || db.Migrator().HasColumn(&models.LoginSession{}, "token_hash") {
+		t.Error("Expected api tokens and session hashes to be removed")
+	}
+
+	if applied, err := Migrate(db); err != nil || applied != steps {
+		t.Errorf("Expected %d migrations to apply. Got %d %v", steps, applied, err)
+	}
+}
+
+func TestRollbackIrreversible(t *testing.T) {
+	db := newTestDB(t)
+
+	if _, err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	reverted, err := Rollback(db, len(migrations))
+	if !errors.Is(err, ErrIrreversible) || reverted != 0 {
+		t.Errorf("Expected ErrIrreversible without reverted migrations. Got %d %v", reverted, err)
+	}
+
+	if version, _ := SchemaVersion(db); version != LatestVersion() {
+		t.Errorf("Expected version %d. Got %d", LatestVersion(), version)
+	}
+}
+
+func TestMigrateSchemaTooNew(t *testing.T) {
+	db := newTestDB(t)
+
+	if _, err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Create(&SchemaMigration{Version: LatestVersion() + 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Migrate(db); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Expected ErrSchemaTooNew. Got %v", err)
+	}
+}
+
+func TestMigrateMatchesModels(t *testing.T) {
+	db := newTestDB(t)
+
+	if _, err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every column of the models has to be created by a migration
+	for _, model := range []interface{}{
+		&models.User{}, &models.LoginSession{}, &models.APIToken{},
+		&models.BuildJob{}, &models.UploadJob{}, &models.Job{},
+		&models.Batch{}, &models.JobDependency{}, &models.JobAttempt{},
+		&models.JobTransition{}, &models.Schedule{}, &services.JobQueueItem{},
+	} {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, field := range stmt.Schema.Fields {
+			if len(field.DBName) > 0 && !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("Missing column %s.%s", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+}
+
+func TestMigrateFromBaseline(t *testing.T) {
+	db := newTestDB(t)
+
+	// Schema of the versions before migrations
+	err := db.AutoMigrate(&userV1{}, &loginSessionV1{}, &buildJobV1{}, &uploadJobV1{}, &jobV1{}, &jobQueueItemV1{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := userV1{Username: "admin", RoleID: models.RoleAdmin}
+	if err := db.Create(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	job := jobV1{}
+	if err := db.Create(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var owner models.Job
+	if err := db.First(&owner, job.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if owner.UserID != admin.ID {
+		t.Errorf("Expected job to be owned by %d. Got %d", admin.ID, owner.UserID)
+	}
+}
+
+func TestAssignOwnersToNewAdmin(t *testing.T) {
+	db := newTestDB(t)
+
+	err := db.AutoMigrate(&userV1{}, &loginSessionV1{}, &buildJobV1{}, &uploadJobV1{}, &jobV1{}, &jobQueueItemV1{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := jobV1{}
+	if err := db.Create(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// No admin to assign the job to
+	if _, err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	user := models.User{Username: "admin"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	role, _ := models.GetRole(models.RoleAdmin)
+	if err := models.SetRole(db, user.Username, role); err != nil {
+		t.Fatal(err)
+	}
+
+	var owner models.Job
+	if err := db.First(&owner, job.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if owner.UserID != user.ID {
+		t.Errorf("Expected job to be owned by %d. Got %d", user.ID, owner.UserID)
+	}
+}
+
+func TestDeleteUserSchedules(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	users := []models.User{{Username: "deleted"}, {Username: "other"}}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, user := range users {
+		_, err := models.NewSchedule(db, models.Schedule{UserID: user.ID, Cron: "@daily"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := models.DeleteUser(db, users[0].Username); err != nil {
+		t.Fatal(err)
+	}
+
+	var schedules []models.Schedule
+	if err := db.Unscoped().Find(&schedules).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(schedules) != 1 || schedules[0].UserID != users[1].ID {
+		t.Errorf("Expected only the schedule of the other user. Got %d schedules", len(schedules))
+	}
+}
+
+func TestMigrateScheduleSecrets(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Schedule of a version storing secrets in Argdata
+	if _, err := Rollback(db, 1); err != nil {
+		t.Fatal(err)
+	}
+	schedule := scheduleV4{Cron: "@daily", Argdata: `{"pkg":"yay","TOKEN":"secret"}`}
+	if err := db.Create(&schedule).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var migrated models.Schedule
+	if err := db.First(&migrated, schedule.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if migrated.Argdata != `{"pkg":"yay"}` || migrated.SecretArgdata != `{"TOKEN":"secret"}` || migrated.SecretArgs != "TOKEN" {
+		t.Errorf("Expected secrets to be moved. Got %+v", migrated)
+	}
+
+	args, err := migrated.GetArgs()
+	if err != nil || args["pkg"] != "yay" || args["TOKEN"] != "secret" {
+		t.Errorf("Expected all args. Got %v %v", args, err)
+	}
+
+	if _, err = Rollback(db, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	var reverted scheduleV4
+	if err = db.First(&reverted, schedule.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if reverted.Argdata != `{"TOKEN":"secret","pkg":"yay"}` {
+		t.Errorf("Expected secrets to be moved back. Got %s", reverted.Argdata)
+	}
+}
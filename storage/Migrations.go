@@ -0,0 +1,436 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/RemoteBuild/Remotebuild/models"
+	"gorm.io/gorm"
+)
+
+// An index on a single column
+type columnIndex struct {
+	table  string
+	column string
+}
+
+// Columns which are used for lookups
+var lookupIndexes = []columnIndex{
+	{"jobs", "user_id"},
+	{"jobs", "batch_id"},
+	{"jobs", "build_job_id"},
+	{"jobs", "upload_job_id"},
+	{"batches", "user_id"},
+	{"job_dependencies", "job_id"},
+	{"job_attempts", "job_id"},
+	{"job_transitions", "job_id"},
+	{"schedules", "user_id"},
+	{"login_sessions", "user_id"},
+	{"login_sessions", "token_hash"},
+	{"api_tokens", "user_id"},
+	{"api_tokens", "token_hash"},
+	{"job_queue", "job_id"},
+}
+
+// migrations all migrations ordered by version. Never change
+// or reorder released migrations, append new ones instead.
+// Migrations only use the frozen structs in Schema.go, never
+// the models. Databases of versions before migrations were
+// added might contain tables and columns of later migrations
+// already, so they are only created if missing
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Initial schema",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &userV1{}, &loginSessionV1{}, &buildJobV1{}, &uploadJobV1{}, &jobV1{}, &jobQueueItemV1{})
+		},
+		// Reverting the initial schema would delete all data
+	},
+	{
+		Version:     2,
+		Description: "Add job priorities",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &jobQueueItemPriority{}, "Priority")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &jobQueueItemPriority{}, "Priority")
+		},
+	},
+	{
+		Version:     3,
+		Description: "Add job dependencies",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &jobDependencyV3{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&jobDependencyV3{})
+		},
+	},
+	{
+		Version:     4,
+		Description: "Add schedules",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &scheduleV4{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&scheduleV4{})
+		},
+	},
+	{
+		Version:     5,
+		Description: "Add retry policies and failed attempts",
+		Up: func(tx *gorm.DB) error {
+			if err := createTables(tx, &jobAttemptV5{}); err != nil {
+				return err
+			}
+
+			return addColumns(tx, &jobRetryPolicy{}, "Attempts", "MaxAttempts", "RetryBackoff", "RetryPhases")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&jobAttemptV5{}); err != nil {
+				return err
+			}
+
+			return dropColumns(tx, &jobRetryPolicy{}, "Attempts", "MaxAttempts", "RetryBackoff", "RetryPhases")
+		},
+	},
+	{
+		Version:     6,
+		Description: "Add build timeouts and container limits",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &buildJobLimits{}, "Timeout", "LimitData")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &buildJobLimits{}, "Timeout", "LimitData")
+		},
+	},
+	{
+		Version:     7,
+		Description: "Store secret job args separately",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &jobSecretArgs{}, "SecretArgdata", "SecretArgs")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &jobSecretArgs{}, "SecretArgdata", "SecretArgs")
+		},
+	},
+	{
+		Version:     8,
+		Description: "Add job stage transitions",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &jobTransitionV8{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&jobTransitionV8{})
+		},
+	},
+	{
+		Version:     9,
+		Description: "Add batches",
+		Up: func(tx *gorm.DB) error {
+			if err := createTables(tx, &batchV9{}); err != nil {
+				return err
+			}
+
+			return addColumns(tx, &jobBatch{}, "BatchID")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&batchV9{}); err != nil {
+				return err
+			}
+
+			return dropColumns(tx, &jobBatch{}, "BatchID")
+		},
+	},
+	{
+		Version:     10,
+		Description: "Add force flag of jobs",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &jobForce{}, "Force")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &jobForce{}, "Force")
+		},
+	},
+	{
+		Version:     11,
+		Description: "Add owners of jobs and batches",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &jobOwner{}, "UserID"); err != nil {
+				return err
+			}
+
+			return addColumns(tx, &batchOwner{}, "UserID")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(tx, &jobOwner{}, "UserID"); err != nil {
+				return err
+			}
+
+			return dropColumns(tx, &batchOwner{}, "UserID")
+		},
+	},
+	{
+		Version:     12,
+		Description: "Assign jobs without owner to the first admin",
+		// Without an admin the jobs get assigned to the
+		// first user who becomes admin. See models.SetRole
+		Up: func(tx *gorm.DB) error {
+			// Role 1 is the admin role
+			var adminID uint
+			err := tx.Table("users").
+				Select("id").
+				Where("role_id = ? AND deleted_at IS NULL", 1).
+				Order("id").
+				Limit(1).
+				Scan(&adminID).Error
+			if err != nil || adminID == 0 {
+				return err
+			}
+
+			for _, table := range []string{"jobs", "batches"} {
+				err = tx.Exec(fmt.Sprintf("UPDATE %s SET user_id = ? WHERE user_id = 0 OR user_id IS NULL", table), adminID).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		// Irreversible: assigned owners can't be told apart
+		// from owners of jobs created by the admin
+	},
+	{
+		Version:     13,
+		Description: "Add API tokens",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &apiTokenV13{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&apiTokenV13{})
+		},
+	},
+	{
+		Version:     14,
+		Description: "Hash session tokens and delete sessions with unhashed tokens",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &loginSessionHash{}, "TokenHash", "LastUsed"); err != nil {
+				return err
+			}
+
+			return tx.Exec("DELETE FROM login_sessions WHERE token_hash IS NULL OR token_hash = ''").Error
+		},
+		// Deleted sessions can't be restored. Sessions
+		// created afterwards are deleted, since they
+		// don't work without their hash
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Exec("DELETE FROM login_sessions").Error; err != nil {
+				return err
+			}
+
+			return dropColumns(tx, &loginSessionHash{}, "TokenHash", "LastUsed")
+		},
+	},
+	{
+		Version:     15,
+		Description: "Add indexes for lookups",
+		Up: func(tx *gorm.DB) error {
+			for _, index := range lookupIndexes {
+				err := tx.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", indexName(index.table, index.column), index.table, index.column)).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, index := range lookupIndexes {
+				if err := tx.Exec(fmt.Sprintf("DROP INDEX IF EXISTS %s", indexName(index.table, index.column))).Error; err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	},
+	{
+		Version:     16,
+		Description: "Store secret schedule args separately",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &scheduleSecretArgs{}, "SecretArgdata", "SecretArgs"); err != nil {
+				return err
+			}
+
+			return splitScheduleArgs(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := mergeScheduleArgs(tx); err != nil {
+				return err
+			}
+
+			return dropColumns(tx, &scheduleSecretArgs{}, "SecretArgdata", "SecretArgs")
+		},
+	},
+}
+
+// Move the secret args of schedules from Argdata to SecretArgdata
+func splitScheduleArgs(tx *gorm.DB) error {
+	var schedules []scheduleSecretArgs
+	if err := tx.Find(&schedules).Error; err != nil {
+		return err
+	}
+
+	for _, schedule := range schedules {
+		args, err := decodeArgs(schedule.Argdata)
+		if err != nil {
+			return err
+		}
+
+		public, secrets := models.SplitArgs(args)
+		if len(secrets) == 0 {
+			continue
+		}
+
+		names := make([]string, 0, len(secrets))
+		for name := range secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		schedule.SecretArgs = strings.Join(names, ",")
+		if schedule.Argdata, err = encodeArgs(public); err != nil {
+			return err
+		}
+		if schedule.SecretArgdata, err = encodeArgs(secrets); err != nil {
+			return err
+		}
+
+		if err = tx.Save(&schedule).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Move the secret args of schedules back to Argdata
+func mergeScheduleArgs(tx *gorm.DB) error {
+	var schedules []scheduleSecretArgs
+	if err := tx.Where("secret_args <> ''").Find(&schedules).Error; err != nil {
+		return err
+	}
+
+	for _, schedule := range schedules {
+		args, err := decodeArgs(schedule.Argdata)
+		if err != nil {
+			return err
+		}
+
+		secrets, err := decodeArgs(schedule.SecretArgdata)
+		if err != nil {
+			return err
+		}
+
+		for k, v := range secrets {
+			args[k] = v
+		}
+
+		if schedule.Argdata, err = encodeArgs(args); err != nil {
+			return err
+		}
+		schedule.SecretArgdata = ""
+		schedule.SecretArgs = ""
+
+		if err = tx.Save(&schedule).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Decode json encoded args
+func decodeArgs(data string) (map[string]string, error) {
+	var args map[string]string
+	if len(data) > 0 {
+		if err := json.Unmarshal([]byte(data), &args); err != nil {
+			return nil, err
+		}
+	}
+
+	if args == nil {
+		args = make(map[string]string)
+	}
+
+	return args, nil
+}
+
+// Encode args as json
+func encodeArgs(args map[string]string) (string, error) {
+	b, err := json.Marshal(args)
+	return string(b), err
+}
+
+// Create the tables of models which don't exist yet
+func createTables(tx *gorm.DB, models ...interface{}) error {
+	for _, model := range models {
+		if tx.Migrator().HasTable(model) {
+			continue
+		}
+
+		if err := tx.Migrator().CreateTable(model); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Add the columns for fields of model which don't exist yet
+func addColumns(tx *gorm.DB, model interface{}, fields ...string) error {
+	for _, field := range fields {
+		if tx.Migrator().HasColumn(model, field) {
+			continue
+		}
+
+		if err := tx.Migrator().AddColumn(model, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Drop the columns for fields of model which exist
+func dropColumns(tx *gorm.DB, model interface{}, fields ...string) error {
+	for _, field := range fields {
+		if !tx.Migrator().HasColumn(model, field) {
+			continue
+		}
+
+		if err := tx.Migrator().DropColumn(model, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Return the table name of a model
+func tableName(db *gorm.DB, model interface{}) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return "", err
+	}
+
+	return stmt.Schema.Table, nil
+}
+
+// Return the name of an index on a single column
+func indexName(table, column string) string {
+	return fmt.Sprintf("idx_%s_%s", table, column)
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Frozen copies of the models as the migrations created them. Never
+// change them, add a new struct and migration for schema changes.
+// Structs with only a few fields describe columns added to an
+// existing table
+
+// Version 1: schema of the versions before migrations
+
+type userV1 struct {
+	gorm.Model
+	Username string
+	Password string
+	RoleID   uint
+}
+
+func (userV1) TableName() string { return "users" }
+
+type loginSessionV1 struct {
+	gorm.Model
+	User      *userV1
+	UserID    uint
+	Token     string
+	Requests  int64
+	MachineID string
+}
+
+func (loginSessionV1) TableName() string { return "login_sessions" }
+
+type buildJobV1 struct {
+	gorm.Model
+	State     uint8
+	Type      uint8
+	Image     string
+	UseCcache bool
+}
+
+func (buildJobV1) TableName() string { return "build_jobs" }
+
+type uploadJobV1 struct {
+	gorm.Model
+	State uint8
+	Type  uint8
+}
+
+func (uploadJobV1) TableName() string { return "upload_jobs" }
+
+type jobV1 struct {
+	gorm.Model
+	BuildJobID  uint
+	BuildJob    *buildJobV1
+	UploadJobID uint
+	UploadJob   *uploadJobV1
+	DataDir     string
+	Result      string
+	LastLogs    string
+	Argdata     string
+	Info        string
+	Duration    int64
+}
+
+func (jobV1) TableName() string { return "jobs" }
+
+type jobQueueItemV1 struct {
+	gorm.Model
+	JobID    uint
+	Job      *jobV1
+	Position uint
+}
+
+func (jobQueueItemV1) TableName() string { return "job_queue" }
+
+// Version 2
+
+type jobQueueItemPriority struct {
+	Priority int8
+}
+
+func (jobQueueItemPriority) TableName() string { return "job_queue" }
+
+// Version 3
+
+type jobDependencyV3 struct {
+	gorm.Model
+	JobID        uint
+	DependencyID uint
+}
+
+func (jobDependencyV3) TableName() string { return "job_dependencies" }
+
+// Version 4
+
+type scheduleV4 struct {
+	gorm.Model
+	UserID        uint
+	User          *userV1
+	Name          string
+	Cron          string
+	BuildType     uint8
+	UploadType    uint8
+	Argdata       string
+	DisableCcache bool
+	Priority      int8
+	Paused        bool
+	LastRun       time.Time
+	NextRun       time.Time
+}
+
+func (scheduleV4) TableName() string { return "schedules" }
+
+// Version 5
+
+type jobAttemptV5 struct {
+	gorm.Model
+	JobID   uint
+	Attempt int
+	Phase   string
+	Error   string
+}
+
+func (jobAttemptV5) TableName() string { return "job_attempts" }
+
+type jobRetryPolicy struct {
+	Attempts     int
+	MaxAttempts  int
+	RetryBackoff int64
+	RetryPhases  string
+}
+
+func (jobRetryPolicy) TableName() string { return "jobs" }
+
+// Version 6
+
+type buildJobLimits struct {
+	Timeout   int64
+	LimitData string
+}
+
+func (buildJobLimits) TableName() string { return "build_jobs" }
+
+// Version 7
+
+type jobSecretArgs struct {
+	SecretArgdata string
+	SecretArgs    string
+}
+
+func (jobSecretArgs) TableName() string { return "jobs" }
+
+// Version 8
+
+type jobTransitionV8 struct {
+	ID     uint `gorm:"primarykey"`
+	JobID  uint
+	Stage  string
+	Reason string
+	Time   time.Time
+}
+
+func (jobTransitionV8) TableName() string { return "job_transitions" }
+
+// Version 9
+
+type batchV9 struct {
+	gorm.Model
+}
+
+func (batchV9) TableName() string { return "batches" }
+
+type jobBatch struct {
+	BatchID uint
+}
+
+func (jobBatch) TableName() string { return "jobs" }
+
+// Version 10
+
+type jobForce struct {
+	Force bool
+}
+
+func (jobForce) TableName() string { return "jobs" }
+
+// Version 11
+
+type jobOwner struct {
+	UserID uint
+}
+
+func (jobOwner) TableName() string { return "jobs" }
+
+type batchOwner struct {
+	UserID uint
+}
+
+func (batchOwner) TableName() string { return "batches" }
+
+// Version 13
+
+type apiTokenV13 struct {
+	gorm.Model
+	User      *userV1
+	UserID    uint
+	Name      string
+	TokenHash string
+	Scopes    string
+	ExpiresAt *time.Time
+	LastUsed  *time.Time
+}
+
+func (apiTokenV13) TableName() string { return "api_tokens" }
+
+// Version 14
+
+type loginSessionHash struct {
+	TokenHash string
+	LastUsed  time.Time
+}
+
+func (loginSessionHash) TableName() string { return "login_sessions" }
+
+// Version 16
+
+// Includes the id and Argdata to move secrets of existing schedules
+type scheduleSecretArgs struct {
+	ID            uint `gorm:"primarykey"`
+	Argdata       string
+	SecretArgdata string
+	SecretArgs    string
+}
+
+func (scheduleSecretArgs) TableName() string { return "schedules" }